@@ -5,6 +5,7 @@ import (
 	"math/rand"
 	"os"
 	"sourcegraph2api/common/env"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,10 +22,42 @@ var UserAgent = env.String("USER_AGENT", "Mozilla/5.0 (Macintosh; Intel Mac OS X
 // 隐藏思考过程
 var ReasoningHide = env.Int("REASONING_HIDE", 0)
 
+// 思考过程输出格式[reasoning_content:以reasoning_content字段输出、think:以<think>标签拼接在content前]
+var ReasoningFormat = env.String("REASONING_FORMAT", "reasoning_content")
+
 // 前置message
 var PRE_MESSAGES_JSON = env.String("PRE_MESSAGES_JSON", "")
 var RateLimitCookieLockDuration = env.Int("RATE_LIMIT_COOKIE_LOCK_DURATION", 60)
 
+// Azure 部署名与模型的映射, 格式: deployment=model,deployment=model
+var AzureDeployments = parseAzureDeployments(env.String("AZURE_DEPLOYMENTS", ""))
+
+// Responses API 结果保存时长(秒)
+var ResponseStoreDuration = env.Int("RESPONSE_STORE_DURATION", 3600)
+
+// gRPC 监听端口, 为空时不启用
+var GrpcPort = env.String("GRPC_PORT", "")
+
+// 因 max_tokens 截断时自动续写的最大次数及累计输出 token 上限
+var AutoContinueMaxRounds = env.Int("AUTO_CONTINUE_MAX_ROUNDS", 3)
+var AutoContinueMaxTokens = env.Int("AUTO_CONTINUE_MAX_TOKENS", 32000)
+
+// 对话超出模型上下文窗口时的裁剪策略[none:不裁剪直接返回错误、drop_oldest:删除最早的消息、keep_last_n:保留system及最后N条消息、middle_out:删除中间的消息]
+var ContextTruncation = env.String("CONTEXT_TRUNCATION", "drop_oldest")
+var ContextKeepLastN = env.Int("CONTEXT_KEEP_LAST_N", 10)
+
+// 对话超出 token 阈值时以摘要模型压缩较早的消息(仅对话接口), 0 表示不开启; 保留最后N条消息不压缩; 摘要缓存时间(秒)
+var CompactionThreshold = env.Int("COMPACTION_THRESHOLD", 0)
+var CompactionModel = env.String("COMPACTION_MODEL", "claude-3-5-haiku-latest")
+var CompactionKeepLastN = env.Int("COMPACTION_KEEP_LAST_N", 6)
+var CompactionCacheDuration = env.Int("COMPACTION_CACHE_DURATION", 3600)
+
+// WebSocket 接口允许的浏览器来源(Origin), 逗号分隔, * 表示不限制; 同源页面及浏览器插件始终允许
+var WebSocketAllowedOrigins = strings.Split(env.String("WS_ALLOWED_ORIGINS", ""), ",")
+
+// 单条消息最大字节数, 超出时拆分为多轮发言, 格式: 默认值,model=字节数,model=字节数, 0 表示不拆分
+var MessageMaxBytes = parseMessageMaxBytes(env.String("MESSAGE_MAX_BYTES", ""))
+
 // 路由前缀
 var RoutePrefix = env.String("ROUTE_PREFIX", "")
 var AllDialogRecordEnable = os.Getenv("ALL_DIALOG_RECORD_ENABLE")
@@ -40,6 +73,45 @@ var (
 	RequestRateLimitDuration int64 = 1 * 60
 )
 
+func parseAzureDeployments(str string) map[string]string {
+	deployments := make(map[string]string)
+	for _, pair := range strings.Split(str, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		deployments[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return deployments
+}
+
+// parseMessageMaxBytes 解析单条消息最大字节数, 不含 = 的项为默认值, key 为空字符串
+func parseMessageMaxBytes(str string) map[string]int {
+	limits := make(map[string]int)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		model, value := "", item
+		if parts := strings.SplitN(item, "=", 2); len(parts) == 2 {
+			model, value = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		}
+		if limit, err := strconv.Atoi(value); err == nil && limit > 0 {
+			limits[model] = limit
+		}
+	}
+	return limits
+}
+
+// GetMessageMaxBytes 获取模型的单条消息最大字节数, 0 表示不拆分
+func GetMessageMaxBytes(model string) int {
+	if limit, ok := MessageMaxBytes[model]; ok {
+		return limit
+	}
+	return MessageMaxBytes[""]
+}
+
 type RateLimitCookie struct {
 	ExpirationTime time.Time // 过期时间
 }
@@ -203,6 +275,19 @@ func (cm *CookieManager) GetNextCookie() (string, error) {
 	return cm.Cookies[cm.currentIndex], nil
 }
 
+// GetCookieAt 获取指定位置的 cookie, 超出数量时循环取值
+func (cm *CookieManager) GetCookieAt(index int) (string, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if len(cm.Cookies) == 0 {
+		return "", errors.New("no cookies available")
+	}
+
+	cm.currentIndex = index % len(cm.Cookies)
+	return cm.Cookies[cm.currentIndex], nil
+}
+
 func (cm *CookieManager) GetRandomCookie() (string, error) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
@@ -1,55 +1,175 @@
 package common
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 var StartTime = time.Now().Unix() // unit: second
 var Version = "v1.1.4"            // this hard coding will be replaced automatically when building, no need to manually change
 
 type SGModelInfo struct {
-	Model     string
-	ModelRef  string
+	Model    string
+	ModelRef string
+	// MaxTokens 最大输出 token 数, 不超过 ContextWindow
 	MaxTokens int
+	// ContextWindow 上下文窗口大小(输入及输出 token 总数)
+	ContextWindow int
+}
+
+// 支持 system 发言的模型提供方
+var systemSpeakerProviders = map[string]bool{
+	"anthropic": true,
+	"openai":    true,
+	"google":    true,
+}
+
+// Provider 模型提供方, 即 ModelRef 的第一段
+func (m SGModelInfo) Provider() string {
+	return strings.SplitN(m.ModelRef, "::", 2)[0]
+}
+
+// SupportsSystem 模型是否支持 system 发言
+func (m SGModelInfo) SupportsSystem() bool {
+	return systemSpeakerProviders[m.Provider()]
+}
+
+// SupportsPrefill 模型是否支持以 assistant 发言结束对话, 从该发言处续写
+func (m SGModelInfo) SupportsPrefill() bool {
+	return m.Provider() == "anthropic"
 }
 
 // 创建映射表（假设用 model 名称作为 key）
 var modelRegistry = map[string]SGModelInfo{
-	"claude-sonnet-4-latest":              {"claude-sonnet-4-latest", "anthropic::2024-10-22::claude-sonnet-4-latest", 64000},
-	"claude-sonnet-4-thinking-latest":     {"claude-sonnet-4-thinking-latest", "anthropic::2024-10-22::claude-sonnet-4-thinking-latest", 64000},
-	"claude-3-7-sonnet-latest":            {"claude-3-7-sonnet-latest", "anthropic::2024-10-22::claude-3-7-sonnet-latest", 64000},
-	"claude-3-7-sonnet-extended-thinking": {"claude-3-7-sonnet-extended-thinking", "anthropic::2024-10-22::claude-3-7-sonnet-extended-thinking", 64000},
-	"claude-3-5-sonnet-latest":            {"claude-3-5-sonnet-latest", "anthropic::2024-10-22::claude-3-5-sonnet-latest", 64000},
-	"claude-3-opus":                       {"claude-3-opus", "anthropic::2023-06-01::claude-3-opus", 64000},
-	"claude-3-5-haiku-latest":             {"claude-3-5-haiku-latest", "anthropic::2024-10-22::claude-3-5-haiku-latest", 64000},
-	"claude-3-haiku":                      {"claude-3-haiku", "anthropic::2023-06-01::claude-3-haiku", 64000},
-	"claude-3.5-sonnet":                   {"claude-3.5-sonnet", "anthropic::2023-06-01::claude-3.5-sonnet", 64000},
-	"claude-3-5-sonnet-20240620":          {"claude-3-5-sonnet-20240620", "anthropic::2023-06-01::claude-3-5-sonnet-20240620", 64000},
-	"claude-3-sonnet":                     {"claude-3-sonnet", "anthropic::2023-06-01::claude-3-sonnet", 64000},
-	"claude-2.1":                          {"claude-2.1", "anthropic::2023-01-01::claude-2.1", 64000},
-	"claude-2.0":                          {"claude-2.0", "anthropic::2023-01-01::claude-2.0", 64000},
-	"deepseek-v3":                         {"deepseek-v3", "fireworks::v1::deepseek-v3", 64000},
-	"gemini-1.5-pro":                      {"gemini-1.5-pro", "google::v1::gemini-1.5-pro", 64000},
-	"gemini-1.5-pro-002":                  {"gemini-1.5-pro-002", "google::v1::gemini-1.5-pro-002", 64000},
-	"gemini-2.0-flash-exp":                {"gemini-2.0-flash-exp", "google::v1::gemini-2.0-flash-exp", 64000},
-	"gemini-2.0-flash":                    {"gemini-2.0-flash", "google::v1::gemini-2.0-flash", 64000},
-	"gemini-2.5-flash-preview-04-17":      {"gemini-2.5-flash-preview-04-17", "google::v1::gemini-2.5-flash-preview-04-17", 64000},
-	"gemini-2.0-flash-lite":               {"gemini-2.0-flash-lite", "google::v1::gemini-2.0-flash-lite", 64000},
-	"gemini-2.0-pro-exp-02-05":            {"gemini-2.0-pro-exp-02-05", "google::v1::gemini-2.0-pro-exp-02-05", 64000},
-	"gemini-2.5-pro-preview-03-25":        {"gemini-2.5-pro-preview-03-25", "google::v1::gemini-2.5-pro-preview-03-25", 64000},
-	"gemini-1.5-flash":                    {"gemini-1.5-flash", "google::v1::gemini-1.5-flash", 64000},
-	"gemini-1.5-flash-002":                {"gemini-1.5-flash-002", "google::v1::gemini-1.5-flash-002", 64000},
-	"mixtral-8x7b-instruct":               {"mixtral-8x7b-instruct", "mistral::v1::mixtral-8x7b-instruct", 64000},
-	"mixtral-8x22b-instruct":              {"mixtral-8x22b-instruct", "mistral::v1::mixtral-8x22b-instruct", 64000},
-	"gpt-4o":                              {"gpt-4o", "openai::2024-02-01::gpt-4o", 64000},
-	"gpt-4.1":                             {"gpt-4.1", "openai::2024-02-01::gpt-4.1", 64000},
-	"gpt-4o-mini":                         {"gpt-4o-mini", "openai::2024-02-01::gpt-4o-mini", 64000},
-	"gpt-4.1-mini":                        {"gpt-4.1-mini", "openai::2024-02-01::gpt-4.1-mini", 64000},
-	"gpt-4.1-nano":                        {"gpt-4.1-nano", "openai::2024-02-01::gpt-4.1-nano", 64000},
-	"o3-mini-medium":                      {"o3-mini-medium", "openai::2024-02-01::o3-mini-medium", 64000},
-	"o3":                                  {"o3", "openai::2024-02-01::o3", 64000},
-	"o4-mini":                             {"o4-mini", "openai::2024-02-01::o4-mini", 64000},
-	"o1":                                  {"o1", "openai::2024-02-01::o1", 64000},
-	"gpt-4-turbo":                         {"gpt-4-turbo", "openai::2024-02-01::gpt-4-turbo", 64000},
-	"gpt-3.5-turbo":                       {"gpt-3.5-turbo", "openai::2024-02-01::gpt-3.5-turbo", 64000},
+	"claude-sonnet-4-latest":              {"claude-sonnet-4-latest", "anthropic::2024-10-22::claude-sonnet-4-latest", 64000, 200000},
+	"claude-sonnet-4-thinking-latest":     {"claude-sonnet-4-thinking-latest", "anthropic::2024-10-22::claude-sonnet-4-thinking-latest", 64000, 200000},
+	"claude-3-7-sonnet-latest":            {"claude-3-7-sonnet-latest", "anthropic::2024-10-22::claude-3-7-sonnet-latest", 64000, 200000},
+	"claude-3-7-sonnet-extended-thinking": {"claude-3-7-sonnet-extended-thinking", "anthropic::2024-10-22::claude-3-7-sonnet-extended-thinking", 64000, 200000},
+	"claude-3-5-sonnet-latest":            {"claude-3-5-sonnet-latest", "anthropic::2024-10-22::claude-3-5-sonnet-latest", 64000, 200000},
+	"claude-3-opus":                       {"claude-3-opus", "anthropic::2023-06-01::claude-3-opus", 64000, 200000},
+	"claude-3-5-haiku-latest":             {"claude-3-5-haiku-latest", "anthropic::2024-10-22::claude-3-5-haiku-latest", 64000, 200000},
+	"claude-3-haiku":                      {"claude-3-haiku", "anthropic::2023-06-01::claude-3-haiku", 64000, 200000},
+	"claude-3.5-sonnet":                   {"claude-3.5-sonnet", "anthropic::2023-06-01::claude-3.5-sonnet", 64000, 200000},
+	"claude-3-5-sonnet-20240620":          {"claude-3-5-sonnet-20240620", "anthropic::2023-06-01::claude-3-5-sonnet-20240620", 64000, 200000},
+	"claude-3-sonnet":                     {"claude-3-sonnet", "anthropic::2023-06-01::claude-3-sonnet", 64000, 200000},
+	"claude-2.1":                          {"claude-2.1", "anthropic::2023-01-01::claude-2.1", 64000, 200000},
+	"claude-2.0":                          {"claude-2.0", "anthropic::2023-01-01::claude-2.0", 64000, 100000},
+	"deepseek-v3":                         {"deepseek-v3", "fireworks::v1::deepseek-v3", 64000, 128000},
+	"gemini-1.5-pro":                      {"gemini-1.5-pro", "google::v1::gemini-1.5-pro", 64000, 2097152},
+	"gemini-1.5-pro-002":                  {"gemini-1.5-pro-002", "google::v1::gemini-1.5-pro-002", 64000, 2097152},
+	"gemini-2.0-flash-exp":                {"gemini-2.0-flash-exp", "google::v1::gemini-2.0-flash-exp", 64000, 1048576},
+	"gemini-2.0-flash":                    {"gemini-2.0-flash", "google::v1::gemini-2.0-flash", 64000, 1048576},
+	"gemini-2.5-flash-preview-04-17":      {"gemini-2.5-flash-preview-04-17", "google::v1::gemini-2.5-flash-preview-04-17", 64000, 1048576},
+	"gemini-2.0-flash-lite":               {"gemini-2.0-flash-lite", "google::v1::gemini-2.0-flash-lite", 64000, 1048576},
+	"gemini-2.0-pro-exp-02-05":            {"gemini-2.0-pro-exp-02-05", "google::v1::gemini-2.0-pro-exp-02-05", 64000, 2097152},
+	"gemini-2.5-pro-preview-03-25":        {"gemini-2.5-pro-preview-03-25", "google::v1::gemini-2.5-pro-preview-03-25", 64000, 1048576},
+	"gemini-1.5-flash":                    {"gemini-1.5-flash", "google::v1::gemini-1.5-flash", 64000, 1048576},
+	"gemini-1.5-flash-002":                {"gemini-1.5-flash-002", "google::v1::gemini-1.5-flash-002", 64000, 1048576},
+	"mixtral-8x7b-instruct":               {"mixtral-8x7b-instruct", "mistral::v1::mixtral-8x7b-instruct", 32768, 32768},
+	"mixtral-8x22b-instruct":              {"mixtral-8x22b-instruct", "mistral::v1::mixtral-8x22b-instruct", 64000, 65536},
+	"gpt-4o":                              {"gpt-4o", "openai::2024-02-01::gpt-4o", 64000, 128000},
+	"gpt-4.1":                             {"gpt-4.1", "openai::2024-02-01::gpt-4.1", 64000, 1047576},
+	"gpt-4o-mini":                         {"gpt-4o-mini", "openai::2024-02-01::gpt-4o-mini", 64000, 128000},
+	"gpt-4.1-mini":                        {"gpt-4.1-mini", "openai::2024-02-01::gpt-4.1-mini", 64000, 1047576},
+	"gpt-4.1-nano":                        {"gpt-4.1-nano", "openai::2024-02-01::gpt-4.1-nano", 64000, 1047576},
+	"o3-mini-medium":                      {"o3-mini-medium", "openai::2024-02-01::o3-mini-medium", 64000, 200000},
+	"o3":                                  {"o3", "openai::2024-02-01::o3", 64000, 200000},
+	"o4-mini":                             {"o4-mini", "openai::2024-02-01::o4-mini", 64000, 200000},
+	"o1":                                  {"o1", "openai::2024-02-01::o1", 64000, 200000},
+	"gpt-4-turbo":                         {"gpt-4-turbo", "openai::2024-02-01::gpt-4-turbo", 64000, 128000},
+	"gpt-3.5-turbo":                       {"gpt-3.5-turbo", "openai::2024-02-01::gpt-3.5-turbo", 4096, 16385},
+}
+
+// 支持图片输入的模型
+var visionModels = map[string]bool{
+	"claude-sonnet-4-latest":              true,
+	"claude-sonnet-4-thinking-latest":     true,
+	"claude-3-7-sonnet-latest":            true,
+	"claude-3-7-sonnet-extended-thinking": true,
+	"claude-3-5-sonnet-latest":            true,
+	"claude-3-opus":                       true,
+	"claude-3-5-haiku-latest":             true,
+	"claude-3-haiku":                      true,
+	"claude-3.5-sonnet":                   true,
+	"claude-3-5-sonnet-20240620":          true,
+	"claude-3-sonnet":                     true,
+	"gemini-1.5-pro":                      true,
+	"gemini-1.5-pro-002":                  true,
+	"gemini-2.0-flash-exp":                true,
+	"gemini-2.0-flash":                    true,
+	"gemini-2.5-flash-preview-04-17":      true,
+	"gemini-2.0-flash-lite":               true,
+	"gemini-2.0-pro-exp-02-05":            true,
+	"gemini-2.5-pro-preview-03-25":        true,
+	"gemini-1.5-flash":                    true,
+	"gemini-1.5-flash-002":                true,
+	"gpt-4o":                              true,
+	"gpt-4.1":                             true,
+	"gpt-4o-mini":                         true,
+	"gpt-4.1-mini":                        true,
+	"gpt-4.1-nano":                        true,
+	"o3":                                  true,
+	"o4-mini":                             true,
+	"o1":                                  true,
+	"gpt-4-turbo":                         true,
+}
+
+// 普通模型与对应的思考模型
+var thinkingModels = map[string]string{
+	"claude-sonnet-4-latest":   "claude-sonnet-4-thinking-latest",
+	"claude-3-7-sonnet-latest": "claude-3-7-sonnet-extended-thinking",
+}
+
+// 以 -low、-medium、-high 后缀区分推理强度的模型
+var effortModelFamilies = []string{"o3-mini"}
+
+// ResolveReasoningModel 根据推理强度(reasoning_effort)选择模型变体, 无对应变体时返回原模型
+// effort 为 none 或 minimal 时关闭思考, 其余值选择思考模型; 以强度后缀区分的模型未注册对应强度时返回错误
+func ResolveReasoningModel(modelName, effort string) (string, error) {
+	requested := effort
+	effort = strings.ToLower(effort)
+	disabled := effort == "none" || effort == "minimal"
+	if effort != "" {
+		for base, thinking := range thinkingModels {
+			if modelName == base && !disabled {
+				return thinking, nil
+			}
+			if modelName == thinking && disabled {
+				return base, nil
+			}
+		}
+	}
+
+	for _, family := range effortModelFamilies {
+		if modelName != family && !strings.HasPrefix(modelName, family+"-") {
+			continue
+		}
+		switch {
+		case disabled:
+			effort = "low"
+		case effort == "" && modelName != family:
+			return modelName, nil
+		case effort == "":
+			effort = "medium"
+		}
+		if _, ok := modelRegistry[family+"-"+effort]; ok {
+			return family + "-" + effort, nil
+		}
+		var available []string
+		for _, candidate := range []string{"low", "medium", "high"} {
+			if _, ok := modelRegistry[family+"-"+candidate]; ok {
+				available = append(available, candidate)
+			}
+		}
+		return modelName, fmt.Errorf("reasoning_effort %s is not available for model %s, supported: %s", requested, modelName, strings.Join(available, ", "))
+	}
+	return modelName, nil
+}
+
+// IsVisionModel 模型是否支持图片输入
+func IsVisionModel(modelName string) bool {
+	return visionModels[modelName]
 }
 
 // 通过 model 名称查询的方法
@@ -13,6 +13,7 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "", "specify the log directory")
+	McpStdio     = flag.Bool("mcp-stdio", false, "run as an MCP server over stdio")
 )
 
 // UploadPath Maybe override by ENV_VAR
@@ -22,7 +23,7 @@ func printHelp() {
 	fmt.Println("sourcegraph2api" + Version + "")
 	fmt.Println("Copyright (C) 2025 Dean. All rights reserved.")
 	fmt.Println("GitHub: https://github.com/deanxv/sourcegraph2api ")
-	fmt.Println("Usage: sourcegraph2api [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("Usage: sourcegraph2api [--port <port>] [--log-dir <log directory>] [--mcp-stdio] [--version] [--help]")
 }
 
 func init() {
@@ -32,7 +32,7 @@ func SetupLogger() {
 			if err != nil {
 				log.Fatal("failed to open log file")
 			}
-			gin.DefaultWriter = io.MultiWriter(os.Stdout, fd)
+			gin.DefaultWriter = io.MultiWriter(gin.DefaultWriter, fd)
 			gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, fd)
 		}
 	})
@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sourcegraph2api/common"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+)
+
+const (
+	anthropicMessageIDFormat = "msg_%s"
+)
+
+// MessagesForAnthropic @Summary Anthropic对话接口
+// @Description Anthropic Messages API 对话接口
+// @Tags Anthropic
+// @Accept json
+// @Produce json
+// @Param req body model.AnthropicMessagesRequest true "Anthropic对话请求"
+// @Param x-api-key header string true "API-KEY"
+// @Router /v1/messages [post]
+func MessagesForAnthropic(c *gin.Context) {
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	var anthropicReq model.AnthropicMessagesRequest
+	if err := c.BindJSON(&anthropicReq); err != nil {
+		logger.Errorf(c.Request.Context(), err.Error())
+		sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", "Invalid request parameters")
+		return
+	}
+	// 按 thinking 预算选择模型变体, 响应中返回实际使用的模型
+	resolved, err := common.ResolveReasoningModel(anthropicReq.Model, anthropicReq.Thinking.ReasoningEffort())
+	if err != nil {
+		sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	anthropicReq.Model = resolved
+	modelInfo, b := common.GetSGModelInfo(anthropicReq.Model)
+	if !b {
+		sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Model %s not supported", anthropicReq.Model))
+		return
+	}
+	if anthropicReq.MaxTokens > modelInfo.MaxTokens {
+		sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Max tokens %d exceeds limit %d", anthropicReq.MaxTokens, modelInfo.MaxTokens))
+		return
+	}
+
+	openAIReq, err := anthropicReq.ToOpenAIRequest()
+	if err != nil {
+		sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if !common.IsVisionModel(openAIReq.Model) && hasImageContent(openAIReq.Messages) {
+		sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf(imageUnsupportedMsg, openAIReq.Model))
+		return
+	}
+	openAIReq.RemoveEmptyContentMessages()
+	if err := checkContextWindow(&openAIReq, modelInfo); err != nil {
+		sendAnthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	if anthropicReq.Stream {
+		handleAnthropicStreamRequest(c, client, anthropicReq, openAIReq)
+	} else {
+		handleAnthropicNonStreamRequest(c, client, anthropicReq, openAIReq)
+	}
+}
+
+func handleAnthropicNonStreamRequest(c *gin.Context, client cycletls.CycleTLS, anthropicReq model.AnthropicMessagesRequest, openAIReq model.OpenAIChatCompletionRequest) {
+	result, err := doUpstreamChat(c.Request.Context(), client, &openAIReq, nil)
+	if err != nil {
+		sendAnthropicError(c, upstreamErrorStatus(err), "api_error", err.Error())
+		return
+	}
+
+	content := result.Content
+	stopSequence := anthropicStopSequence(result, openAIReq.StopSequences())
+	stopReason := anthropicStopReason(result, stopSequence)
+	// 思考过程以 thinking 块返回, 不受 REASONING_FORMAT 影响
+	var blocks []model.AnthropicContentBlock
+	if result.Reasoning != "" && !reasoningHidden() {
+		blocks = append(blocks, model.AnthropicContentBlock{Type: "thinking", Thinking: result.Reasoning})
+	}
+	blocks = append(blocks, model.AnthropicContentBlock{Type: "text", Text: content})
+	c.JSON(http.StatusOK, model.AnthropicMessagesResponse{
+		ID:           fmt.Sprintf(anthropicMessageIDFormat, common.GetUUID()),
+		Type:         "message",
+		Role:         "assistant",
+		Model:        anthropicReq.Model,
+		Content:      blocks,
+		StopReason:   &stopReason,
+		StopSequence: stopSequence,
+		Usage: model.AnthropicUsage{
+			InputTokens:  model.CountTokenMessages(openAIReq.Messages, openAIReq.Model),
+			OutputTokens: model.CountTokenText(content, openAIReq.Model) + reasoningTokens(result.Reasoning, openAIReq.Model),
+		},
+	})
+}
+
+func handleAnthropicStreamRequest(c *gin.Context, client cycletls.CycleTLS, anthropicReq model.AnthropicMessagesRequest, openAIReq model.OpenAIChatCompletionRequest) {
+	messageId := fmt.Sprintf(anthropicMessageIDFormat, common.GetUUID())
+	inputTokens := model.CountTokenMessages(openAIReq.Messages, openAIReq.Model)
+	blockIndex := 0
+	blockType := ""
+	started := false
+
+	// 首个增量到达时再写出响应头, 以便上游失败时仍能返回错误状态码
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		sendAnthropicEvent(c, "message_start", model.AnthropicStreamEvent{
+			Type: "message_start",
+			Message: &model.AnthropicMessagesResponse{
+				ID:      messageId,
+				Type:    "message",
+				Role:    "assistant",
+				Model:   anthropicReq.Model,
+				Content: []model.AnthropicContentBlock{},
+				Usage:   model.AnthropicUsage{InputTokens: inputTokens, OutputTokens: 1},
+			},
+		})
+	}
+	// openBlock 切换到 thinking 或 text 块, 先结束上一个块
+	openBlock := func(kind string) {
+		start()
+		if blockType == kind {
+			return
+		}
+		if blockType != "" {
+			sendAnthropicEvent(c, "content_block_stop", model.AnthropicStreamEvent{
+				Type:  "content_block_stop",
+				Index: &blockIndex,
+			})
+			blockIndex++
+		}
+		blockType = kind
+		sendAnthropicEvent(c, "content_block_start", model.AnthropicStreamEvent{
+			Type:         "content_block_start",
+			Index:        &blockIndex,
+			ContentBlock: &model.AnthropicContentBlock{Type: kind},
+		})
+	}
+
+	// 思考过程以 thinking_delta 输出, 不受 REASONING_FORMAT 影响
+	var onReasoning deltaHandler
+	if !reasoningHidden() {
+		onReasoning = func(thinking string) bool {
+			openBlock("thinking")
+			sendAnthropicEvent(c, "content_block_delta", model.AnthropicStreamEvent{
+				Type:  "content_block_delta",
+				Index: &blockIndex,
+				Delta: model.AnthropicThinkingDelta{Type: "thinking_delta", Thinking: thinking},
+			})
+			return true
+		}
+	}
+
+	result, err := doUpstreamReasoningChat(c.Request.Context(), client, &openAIReq, onReasoning, func(delta string) bool {
+		openBlock("text")
+		sendAnthropicEvent(c, "content_block_delta", model.AnthropicStreamEvent{
+			Type:  "content_block_delta",
+			Index: &blockIndex,
+			Delta: model.AnthropicTextDelta{Type: "text_delta", Text: delta},
+		})
+		return true
+	})
+	if err != nil {
+		if !started {
+			sendAnthropicError(c, upstreamErrorStatus(err), "api_error", err.Error())
+			return
+		}
+		sendAnthropicEvent(c, "error", model.AnthropicErrorResponse{
+			Type:  "error",
+			Error: model.AnthropicError{Type: "api_error", Message: err.Error()},
+		})
+		return
+	}
+	// 没有正文时仍返回空的 text 块
+	openBlock("text")
+
+	stopSequence := anthropicStopSequence(result, openAIReq.StopSequences())
+	stopReason := anthropicStopReason(result, stopSequence)
+	sendAnthropicEvent(c, "content_block_stop", model.AnthropicStreamEvent{
+		Type:  "content_block_stop",
+		Index: &blockIndex,
+	})
+	sendAnthropicEvent(c, "message_delta", model.AnthropicStreamEvent{
+		Type:  "message_delta",
+		Delta: model.AnthropicMessageDelta{StopReason: &stopReason, StopSequence: stopSequence},
+		Usage: &model.AnthropicUsage{OutputTokens: model.CountTokenText(result.Content, openAIReq.Model) + reasoningTokens(result.Reasoning, openAIReq.Model)},
+	})
+	sendAnthropicEvent(c, "message_stop", model.AnthropicStreamEvent{
+		Type: "message_stop",
+	})
+}
+
+// anthropicStopSequence 获取匹配到的停止序列
+// 上游按转发的停止序列结束时不返回具体序列, 仅在请求只有一个停止序列时可以确定
+func anthropicStopSequence(result *upstreamResult, stops []string) *string {
+	if result.StopSequence != "" {
+		return &result.StopSequence
+	}
+	if result.stoppedBySequence() && len(stops) == 1 {
+		return &stops[0]
+	}
+	return nil
+}
+
+// anthropicStopReason 将上游结束原因转换为 Anthropic stop_reason
+func anthropicStopReason(result *upstreamResult, stopSequence *string) string {
+	if stopSequence != nil || result.stoppedBySequence() {
+		return "stop_sequence"
+	}
+	switch result.finishReason() {
+	case "length":
+		return "max_tokens"
+	case "content_filter":
+		return "refusal"
+	}
+	return "end_turn"
+}
+
+// sendAnthropicEvent 发送 Anthropic SSE 事件
+func sendAnthropicEvent(c *gin.Context, name string, event interface{}) {
+	jsonResp, err := json.Marshal(event)
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "Failed to marshal response: %v", err)
+		return
+	}
+	c.SSEvent(name, " "+string(jsonResp))
+	c.Writer.Flush()
+}
+
+func sendAnthropicError(c *gin.Context, status int, errorType, message string) {
+	c.JSON(status, model.AnthropicErrorResponse{
+		Type: "error",
+		Error: model.AnthropicError{
+			Type:    errorType,
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sourcegraph2api/common"
+	"sourcegraph2api/common/config"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+)
+
+const (
+	azureFlavorKey = "azure_flavor"
+)
+
+// ChatForAzure @Summary Azure OpenAI对话接口
+// @Description Azure OpenAI 部署风格对话接口, 部署名通过 AZURE_DEPLOYMENTS 映射到模型
+// @Tags Azure
+// @Accept json
+// @Produce json
+// @Param deployment path string true "部署名"
+// @Param api-version query string false "API 版本"
+// @Param req body model.OpenAIChatCompletionRequest true "OpenAI对话请求"
+// @Param api-key header string true "API-KEY"
+// @Router /openai/deployments/{deployment}/chat/completions [post]
+func ChatForAzure(c *gin.Context) {
+	c.Set(azureFlavorKey, true)
+
+	deployment := c.Param("deployment")
+	modelName, ok := azureDeploymentModel(deployment)
+	if !ok {
+		sendAzureError(c, http.StatusNotFound, "DeploymentNotFound", fmt.Sprintf("The API deployment %s for this resource does not exist.", deployment))
+		return
+	}
+
+	var openAIReq model.OpenAIChatCompletionRequest
+	if err := c.BindJSON(&openAIReq); err != nil {
+		logger.Errorf(c.Request.Context(), err.Error())
+		sendAzureError(c, http.StatusBadRequest, "BadRequest", "Invalid request parameters")
+		return
+	}
+	// Azure 以部署名决定模型, 忽略请求体中的 model
+	openAIReq.Model = modelName
+
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	handleChatCompletion(c, client, openAIReq)
+}
+
+// azureDeploymentModel 获取部署名对应的模型, 未配置映射时部署名即模型名
+func azureDeploymentModel(deployment string) (string, bool) {
+	modelName, ok := config.AzureDeployments[deployment]
+	if !ok {
+		modelName = deployment
+	}
+	_, ok = common.GetSGModelInfo(modelName)
+	return modelName, ok
+}
+
+func isAzureFlavor(c *gin.Context) bool {
+	return c.GetBool(azureFlavorKey)
+}
+
+// applyAzureFlavor 为 Azure 风格请求补充内容过滤结果
+func applyAzureFlavor(c *gin.Context, response *model.OpenAIChatCompletionResponse, withPrompt bool) {
+	if !isAzureFlavor(c) {
+		return
+	}
+	if withPrompt {
+		response.PromptFilterResults = azurePromptFilterResults()
+	}
+	for i := range response.Choices {
+		response.Choices[i].ContentFilterResults = azureContentFilterResults()
+	}
+}
+
+func azureContentFilterResults() *model.AzureContentFilterResults {
+	safe := model.AzureContentFilterResult{Filtered: false, Severity: "safe"}
+	return &model.AzureContentFilterResults{
+		Hate:     safe,
+		SelfHarm: safe,
+		Sexual:   safe,
+		Violence: safe,
+	}
+}
+
+func azurePromptFilterResults() []model.AzurePromptFilterResult {
+	return []model.AzurePromptFilterResult{{
+		PromptIndex:          0,
+		ContentFilterResults: *azureContentFilterResults(),
+	}}
+}
+
+func sendAzureError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, model.AzureErrorResponse{
+		Error: model.AzureError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
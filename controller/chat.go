@@ -1,18 +1,18 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/deanxv/CycleTLS/cycletls"
 	"github.com/gin-gonic/gin"
-	"io"
 	"net/http"
 	"sourcegraph2api/common"
 	"sourcegraph2api/common/config"
 	logger "sourcegraph2api/common/loggger"
 	"sourcegraph2api/model"
-	"sourcegraph2api/sourcegraphapi"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -44,30 +44,24 @@ func ChatForOpenAI(c *gin.Context) {
 		})
 		return
 	}
-	modelInfo, b := common.GetSGModelInfo(openAIReq.Model)
-	if !b {
-		c.JSON(http.StatusBadRequest, model.OpenAIErrorResponse{
-			OpenAIError: model.OpenAIError{
-				Message: fmt.Sprintf("Model %s not supported", openAIReq.Model),
-				Type:    "invalid_request_error",
-				Code:    "invalid_model",
-			},
-		})
-		return
+
+	handleChatCompletion(c, client, openAIReq)
+}
+
+// handleChatCompletion 校验并处理对话请求, 供 OpenAI 及 Azure 等兼容接口共用
+func handleChatCompletion(c *gin.Context, client cycletls.CycleTLS, openAIReq model.OpenAIChatCompletionRequest) {
+	headers, reqErr := prepareChatRequest(c.Request.Context(), client, &openAIReq, chatRequestOptions{
+		AutoContinue: c.GetHeader(autoContinueHeader) == "true",
+		Truncation:   c.GetHeader(contextTruncationHeader),
+	})
+	for key, value := range headers {
+		c.Header(key, value)
 	}
-	if openAIReq.MaxTokens > modelInfo.MaxTokens {
-		c.JSON(http.StatusBadRequest, model.OpenAIErrorResponse{
-			OpenAIError: model.OpenAIError{
-				Message: fmt.Sprintf("Max tokens %d exceeds limit %d", openAIReq.MaxTokens, modelInfo.MaxTokens),
-				Type:    "invalid_request_error",
-				Code:    "invalid_max_tokens",
-			},
-		})
+	if reqErr != nil {
+		sendChatError(c, reqErr.Status, reqErr.Type, reqErr.Code, reqErr.Message)
 		return
 	}
 
-	openAIReq.RemoveEmptyContentMessages()
-
 	if openAIReq.Stream {
 		handleStreamRequest(c, client, openAIReq)
 	} else {
@@ -75,152 +69,176 @@ func ChatForOpenAI(c *gin.Context) {
 	}
 }
 
-func handleNonStreamRequest(c *gin.Context, client cycletls.CycleTLS, openAIReq model.OpenAIChatCompletionRequest) {
-	responseId := fmt.Sprintf(responseIDFormat, time.Now().Format("20060102150405"))
-	ctx := c.Request.Context()
-	cookieManager := config.NewCookieManager()
-	maxRetries := len(cookieManager.Cookies)
-	cookie, err := cookieManager.GetRandomCookie()
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
-		return
-	}
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		requestBody, err := createRequestBody(c, &openAIReq)
-		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
-			return
-		}
-
-		jsonData, err := json.Marshal(requestBody)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "Failed to marshal request body"})
-			return
-		}
-		sseChan, err := sourcegraphapi.MakeStreamChatRequest(c, client, jsonData, cookie)
-		if err != nil {
-			logger.Errorf(ctx, "MakeStreamChatRequest err on attempt %d: %v", attempt+1, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+// chatRequestOptions 由请求头等接口层传入的选项
+type chatRequestOptions struct {
+	AutoContinue bool
+	// Truncation 裁剪策略, 为空时使用 CONTEXT_TRUNCATION
+	Truncation string
+}
 
-		isRateLimit := false
-		var delta string
-		var assistantMsgContent string
-		var shouldContinue bool
-		thinkStartType := new(bool) // 初始值为false
-	SSELoop:
-		for response := range sseChan {
-
-			if response.Status == 400 {
-				isRateLimit = true
-				logger.Errorf(ctx, fmt.Sprintf("No permission to call this model:%s", openAIReq.Model))
-				break SSELoop // 使用 label 跳出 SSE 循环
-			}
-			if response.Status == 429 {
-				isRateLimit = true
-				logger.Warnf(ctx, "Cookie rate limited, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
-				config.AddRateLimitCookie(cookie, time.Now().Add(time.Duration(config.RateLimitCookieLockDuration)*time.Second))
-				break SSELoop // 使用 label 跳出 SSE 循环
-			}
+// chatRequestError 对话请求校验失败
+type chatRequestError struct {
+	Status  int
+	Type    string
+	Code    string
+	Message string
+}
 
-			if response.Done {
-				logger.Debugf(ctx, response.Data)
-				return
-			}
+func invalidChatRequest(code, message string) *chatRequestError {
+	return &chatRequestError{Status: http.StatusBadRequest, Type: "invalid_request_error", Code: code, Message: message}
+}
 
-			data := response.Data
-			if data == "" {
-				continue
-			}
+// prepareChatRequest 校验对话请求并选择模型变体、压缩及裁剪历史消息, HTTP 与 WebSocket 接口共用
+// 返回需要写入响应头的信息
+func prepareChatRequest(ctx context.Context, client cycletls.CycleTLS, openAIReq *model.OpenAIChatCompletionRequest, options chatRequestOptions) (map[string]string, *chatRequestError) {
+	// 按 reasoning_effort 选择模型变体, 响应中返回实际使用的模型
+	resolved, err := common.ResolveReasoningModel(openAIReq.Model, openAIReq.ReasoningEffort)
+	if err != nil {
+		return nil, invalidChatRequest("invalid_reasoning_effort", err.Error())
+	}
+	openAIReq.Model = resolved
+	modelInfo, b := common.GetSGModelInfo(openAIReq.Model)
+	if !b {
+		return nil, invalidChatRequest("invalid_model", fmt.Sprintf("Model %s not supported", openAIReq.Model))
+	}
+	if openAIReq.MaxTokens > modelInfo.MaxTokens {
+		return nil, invalidChatRequest("invalid_max_tokens", fmt.Sprintf("Max tokens %d exceeds limit %d", openAIReq.MaxTokens, modelInfo.MaxTokens))
+	}
+	if openAIReq.MaxTokens >= modelInfo.ContextWindow {
+		return nil, invalidChatRequest("context_length_exceeded", fmt.Sprintf("Max tokens %d exceeds the context window %d of model %s", openAIReq.MaxTokens, modelInfo.ContextWindow, openAIReq.Model))
+	}
 
-			logger.Debug(ctx, strings.TrimSpace(data))
-
-			switch {
-			case common.IsCloudflareChallenge(data):
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "cf challenge"})
-				return
-			case common.IsNotLogin(data):
-				isRateLimit = true
-				logger.Warnf(ctx, "Cookie Not Login, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
-				// 删除cookie
-				//config.RemoveCookie(cookie)
-				break SSELoop
-			}
+	if openAIReq.N < 0 || openAIReq.N > maxChoices {
+		return nil, invalidChatRequest("invalid_n", fmt.Sprintf("n must be between 1 and %d", maxChoices))
+	}
+	if len(openAIReq.StopSequences()) > maxStopSequences {
+		return nil, invalidChatRequest("invalid_stop", fmt.Sprintf("stop accepts at most %d sequences", maxStopSequences))
+	}
+	if err := validateMessageContent(openAIReq.Messages); err != nil {
+		return nil, invalidChatRequest("invalid_content", err.Error())
+	}
+	if !common.IsVisionModel(openAIReq.Model) && hasImageContent(openAIReq.Messages) {
+		return nil, invalidChatRequest("image_not_supported", fmt.Sprintf(imageUnsupportedMsg, openAIReq.Model))
+	}
+	if _, err := compileResponseSchema(openAIReq.ResponseFormat); err != nil {
+		return nil, invalidChatRequest("invalid_response_format", fmt.Sprintf("Invalid response_format json_schema: %v", err))
+	}
 
-			streamDelta, streamShouldContinue := processNoStreamData(c, data, responseId, openAIReq.Model, jsonData, thinkStartType)
-			delta = streamDelta
-			shouldContinue = streamShouldContinue
-			// 处理事件流数据
-			if !shouldContinue {
-				promptTokens := model.CountTokenText(string(jsonData), openAIReq.Model)
-				completionTokens := model.CountTokenText(assistantMsgContent, openAIReq.Model)
-				finishReason := "stop"
-
-				c.JSON(http.StatusOK, model.OpenAIChatCompletionResponse{
-					ID:      fmt.Sprintf(responseIDFormat, time.Now().Format("20060102150405")),
-					Object:  "chat.completion",
-					Created: time.Now().Unix(),
-					Model:   openAIReq.Model,
-					Choices: []model.OpenAIChoice{{
-						Message: model.OpenAIMessage{
-							Role:    "assistant",
-							Content: assistantMsgContent,
-						},
-						FinishReason: &finishReason,
-					}},
-					Usage: model.OpenAIUsage{
-						PromptTokens:     promptTokens,
-						CompletionTokens: completionTokens,
-						TotalTokens:      promptTokens + completionTokens,
-					},
-				})
+	if options.AutoContinue {
+		openAIReq.AutoContinue = true
+	}
+	openAIReq.RemoveEmptyContentMessages()
 
-				return
-			} else {
-				//if strings.TrimSpace(delta) != "" {
-				assistantMsgContent = assistantMsgContent + delta
+	strategy := config.ContextTruncation
+	if options.Truncation != "" {
+		strategy = options.Truncation
+	}
+	if !isTruncationStrategy(strategy) {
+		return nil, invalidChatRequest("invalid_truncation", fmt.Sprintf("Unsupported context truncation strategy %s", strategy))
+	}
 
-				//}
-			}
-		}
-		if !isRateLimit {
-			return
-		}
+	headers := make(map[string]string)
+	// 超出压缩阈值时将较早的消息替换为摘要, 摘要失败时继续按裁剪策略处理
+	compacted, err := compactMessages(ctx, client, openAIReq)
+	if err != nil {
+		logger.Warnf(ctx, "History compaction err: %v", err)
+	}
+	if compacted > 0 {
+		headers[historyCompactedHeader] = strconv.Itoa(compacted)
+	}
 
-		// 获取下一个可用的cookie继续尝试
-		cookie, err = cookieManager.GetNextCookie()
-		if err != nil {
-			logger.Errorf(ctx, "No more valid cookies available after attempt %d", attempt+1)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	// 超出上下文窗口时按策略裁剪历史消息, 响应头返回实际应用的策略
+	removed, err := truncateMessages(openAIReq, modelInfo, strategy)
+	if removed > 0 {
+		headers[contextTruncationHeader] = strategy
+		headers[contextTruncatedHeader] = strconv.Itoa(removed)
+	}
+	if err != nil {
+		return headers, invalidChatRequest("context_length_exceeded", err.Error())
+	}
+	return headers, nil
+}
 
+func handleNonStreamRequest(c *gin.Context, client cycletls.CycleTLS, openAIReq model.OpenAIChatCompletionRequest) {
+	response, err := createChatCompletion(c.Request.Context(), client, &openAIReq)
+	if err != nil {
+		sendChatUpstreamError(c, err)
+		return
 	}
-	logger.Errorf(ctx, "All cookies exhausted after %d attempts", maxRetries)
-	c.JSON(http.StatusInternalServerError, gin.H{"error": "All cookies are temporarily unavailable."})
-	return
+	applyAzureFlavor(c, &response, true)
+	c.JSON(http.StatusOK, response)
 }
 
-func createRequestBody(c *gin.Context, req *model.OpenAIChatCompletionRequest) (map[string]interface{}, error) {
-	messages := make([]map[string]interface{}, len(req.Messages))
-	for i, msg := range req.Messages {
+// createChatCompletion 生成各回复并组装非流式响应, 处理工具调用、结构化输出及思考过程, HTTP 与 gRPC 接口共用
+func createChatCompletion(ctx context.Context, client cycletls.CycleTLS, openAIReq *model.OpenAIChatCompletionRequest) (model.OpenAIChatCompletionResponse, error) {
+	responseId := fmt.Sprintf(responseIDFormat, time.Now().Format("20060102150405"))
 
-		speaker := msg.Role
-		if msg.Role == "user" {
-			speaker = "human"
+	results, err := doUpstreamChoices(ctx, openAIReq, func(ctx context.Context, index int) (*upstreamResult, error) {
+		if openAIReq.ResponseFormat.IsJSON() {
+			return doStructuredUpstreamChat(ctx, client, openAIReq)
 		}
+		return doUpstreamChat(ctx, client, openAIReq, nil)
+	})
+	if err != nil {
+		return model.OpenAIChatCompletionResponse{}, err
+	}
 
-		messages[i] = map[string]interface{}{
-			"speaker": speaker,
-			"text":    msg.Content,
+	choices := make([]model.OpenAIChoice, 0, len(results))
+	for index, result := range results {
+		// 失败的回复不返回, 其余回复保持原序号
+		if result == nil {
+			continue
+		}
+		finishReason := result.finishReason()
+
+		message := model.OpenAIMessage{
+			Role:    "assistant",
+			Content: result.Content,
+		}
+		if toolsEnabled(openAIReq) {
+			if text, toolCalls, ok := parseToolCalls(result.Content); ok {
+				message.Content = text
+				message.ToolCalls = toolCalls
+				finishReason = "tool_calls"
+			}
 		}
+		applyReasoning(&message, result.Reasoning)
+		choices = append(choices, model.OpenAIChoice{
+			Index:        index,
+			Message:      message,
+			FinishReason: &finishReason,
+		})
 	}
 
+	return model.OpenAIChatCompletionResponse{
+		ID:      responseId,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   openAIReq.Model,
+		Choices: choices,
+		Usage:   chatUsage(openAIReq, results),
+	}, nil
+}
+
+func createRequestBody(ctx context.Context, req *model.OpenAIChatCompletionRequest) (map[string]interface{}, error) {
 	modelInfo, b := common.GetSGModelInfo(req.Model)
 	if !b {
 		return nil, fmt.Errorf("model %s not found", req.Model)
 	}
+	if err := validateMessageContent(req.Messages); err != nil {
+		return nil, &upstreamError{StatusCode: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	turns, err := normalizeMessages(ctx, req, modelInfo, systemPrompts(req))
+	if err != nil {
+		return nil, err
+	}
+	turns = splitOversizedTurns(turns, config.GetMessageMaxBytes(req.Model))
+	messages := make([]map[string]interface{}, 0, len(turns))
+	for _, turn := range turns {
+		messages = append(messages, turn.toMap())
+	}
+
 	if req.MaxTokens <= 1 {
 		req.MaxTokens = 4000
 	}
@@ -234,16 +252,70 @@ func createRequestBody(c *gin.Context, req *model.OpenAIChatCompletionRequest) (
 		"topP":              -1,
 		"topK":              -1,
 	}
+	if stops := req.StopSequences(); len(stops) > 0 {
+		requestBody["stopSequences"] = stops
+	}
 
-	logger.Debug(c.Request.Context(), fmt.Sprintf("RequestBody: %v", requestBody))
+	logger.Debug(ctx, fmt.Sprintf("RequestBody: %v", requestBody))
 
 	return requestBody, nil
 }
 
-// createStreamResponse 创建流式响应
-func createStreamResponse(responseId, modelName string, jsonData []byte, delta model.OpenAIDelta, finishReason *string) model.OpenAIChatCompletionResponse {
-	promptTokens := model.CountTokenText(string(jsonData), modelName)
-	completionTokens := model.CountTokenText(delta.Content, modelName)
+// systemPrompts 模拟工具调用及 response_format 时注入的提示词
+func systemPrompts(req *model.OpenAIChatCompletionRequest) []string {
+	var prompts []string
+	for _, prompt := range []string{toolPrompt(req), responseFormatPrompt(req)} {
+		if prompt != "" {
+			prompts = append(prompts, prompt)
+		}
+	}
+	return prompts
+}
+
+// chatUsage 统计各回复的用量, 优先使用上游返回的用量, 提示词按消息内容计算
+func chatUsage(openAIReq *model.OpenAIChatCompletionRequest, results []*upstreamResult) *model.OpenAIUsage {
+	usage := &model.OpenAIUsage{}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if result.Usage != nil && result.Usage.PromptTokens > 0 && usage.PromptTokens == 0 {
+			usage.PromptTokens = result.Usage.PromptTokens
+		}
+		if result.Usage != nil && result.Usage.CompletionTokens > 0 {
+			usage.CompletionTokens += result.Usage.CompletionTokens
+		} else {
+			usage.CompletionTokens += model.CountTokenText(result.Content, openAIReq.Model)
+			usage.CompletionTokens += reasoningTokens(result.Reasoning, openAIReq.Model)
+		}
+		if result.Reasoning != "" {
+			if usage.CompletionTokensDetails == nil {
+				usage.CompletionTokensDetails = &model.OpenAICompletionTokensDetails{}
+			}
+			usage.CompletionTokensDetails.ReasoningTokens += reasoningTokens(result.Reasoning, openAIReq.Model)
+		}
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = model.CountTokenMessages(openAIReq.Messages, openAIReq.Model)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// createUsageResponse 创建流式响应的用量分片, choices 为空
+func createUsageResponse(responseId, modelName string, usage *model.OpenAIUsage) model.OpenAIChatCompletionResponse {
+	return model.OpenAIChatCompletionResponse{
+		ID:      responseId,
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   modelName,
+		Choices: []model.OpenAIChoice{},
+		Usage:   usage,
+	}
+}
+
+// createStreamResponse 创建流式响应, 内容分片不携带用量
+func createStreamResponse(responseId, modelName string, delta model.OpenAIDelta, finishReason *string) model.OpenAIChatCompletionResponse {
 	return model.OpenAIChatCompletionResponse{
 		ID:      responseId,
 		Object:  "chat.completion.chunk",
@@ -256,52 +328,77 @@ func createStreamResponse(responseId, modelName string, jsonData []byte, delta m
 				FinishReason: finishReason,
 			},
 		},
-		Usage: model.OpenAIUsage{
-			PromptTokens:     promptTokens,
-			CompletionTokens: completionTokens,
-			TotalTokens:      promptTokens + completionTokens,
-		},
 	}
 }
 
-// handleDelta 处理消息字段增量
-func handleDelta(c *gin.Context, delta string, responseId, modelName string, jsonData []byte) error {
+// chunkSender 发送一个流式分片, HTTP 以 SSE 事件发送, WebSocket 以 delta 帧发送
+type chunkSender func(response model.OpenAIChatCompletionResponse) error
+
+// handleDelta 处理消息字段增量, index 为回复序号
+func handleDelta(send chunkSender, index int, delta string, responseId, modelName string) error {
 	// 创建基础响应
 	createResponse := func(content string) model.OpenAIChatCompletionResponse {
-		return createStreamResponse(
+		response := createStreamResponse(
 			responseId,
 			modelName,
-			jsonData,
 			model.OpenAIDelta{Content: content, Role: "assistant"},
 			nil,
 		)
+		response.Choices[0].Index = index
+		return response
 	}
 
 	// 发送基础事件
-	var err error
-	if err = sendSSEvent(c, createResponse(delta)); err != nil {
-		return err
-	}
+	return send(createResponse(delta))
+}
 
-	return err
+// handleReasoningDelta 发送思考过程增量(reasoning_content)
+func handleReasoningDelta(send chunkSender, index int, reasoning string, responseId, modelName string) error {
+	response := createStreamResponse(responseId, modelName, model.OpenAIDelta{Role: "assistant", ReasoningContent: reasoning}, nil)
+	response.Choices[0].Index = index
+	return send(response)
 }
 
-// handleMessageResult 处理消息结果
-func handleMessageResult(c *gin.Context, responseId, modelName string, jsonData []byte) bool {
-	finishReason := "stop"
+// handleToolCallsDelta 发送工具调用增量, 每个调用先发送 id 及函数名, 再发送参数
+func handleToolCallsDelta(send chunkSender, index int, toolCalls []model.OpenAIToolCall, responseId, modelName string) error {
+	for i, toolCall := range toolCalls {
+		callIndex := i
+		header := model.OpenAIToolCall{
+			Index:    &callIndex,
+			ID:       toolCall.ID,
+			Type:     toolCall.Type,
+			Function: model.OpenAIFunctionCall{Name: toolCall.Function.Name},
+		}
+		arguments := model.OpenAIToolCall{
+			Index:    &callIndex,
+			Function: model.OpenAIFunctionCall{Arguments: toolCall.Function.Arguments},
+		}
+		for _, call := range []model.OpenAIToolCall{header, arguments} {
+			response := createStreamResponse(responseId, modelName, model.OpenAIDelta{Role: "assistant", ToolCalls: []model.OpenAIToolCall{call}}, nil)
+			response.Choices[0].Index = index
+			if err := send(response); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handleMessageResult 处理消息结果, 发送第 index 个回复的结束原因
+func handleMessageResult(ctx context.Context, send chunkSender, index int, responseId, modelName string, finishReason string) bool {
 	var delta string
 
-	streamResp := createStreamResponse(responseId, modelName, jsonData, model.OpenAIDelta{Content: delta, Role: "assistant"}, &finishReason)
-	if err := sendSSEvent(c, streamResp); err != nil {
-		logger.Warnf(c.Request.Context(), "sendSSEvent err: %v", err)
+	streamResp := createStreamResponse(responseId, modelName, model.OpenAIDelta{Content: delta, Role: "assistant"}, &finishReason)
+	streamResp.Choices[0].Index = index
+	if err := send(streamResp); err != nil {
+		logger.Warnf(ctx, "send chunk err: %v", err)
 		return false
 	}
-	c.SSEvent("", " [DONE]")
 	return false
 }
 
 // sendSSEvent 发送SSE事件
-func sendSSEvent(c *gin.Context, response model.OpenAIChatCompletionResponse) error {
+func sendSSEvent(c *gin.Context, response interface{}) error {
 	jsonResp, err := json.Marshal(response)
 	if err != nil {
 		logger.Errorf(c.Request.Context(), "Failed to marshal response: %v", err)
@@ -313,208 +410,183 @@ func sendSSEvent(c *gin.Context, response model.OpenAIChatCompletionResponse) er
 }
 
 func handleStreamRequest(c *gin.Context, client cycletls.CycleTLS, openAIReq model.OpenAIChatCompletionRequest) {
-
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-
 	responseId := fmt.Sprintf(responseIDFormat, time.Now().Format("20060102150405"))
 	ctx := c.Request.Context()
-	cookieManager := config.NewCookieManager()
-	maxRetries := len(cookieManager.Cookies)
-	cookie, err := cookieManager.GetRandomCookie()
+
+	// 首个分片发送前再写出响应头, 以便上游失败时仍能返回错误状态码
+	started := false
+	send := func(response model.OpenAIChatCompletionResponse) error {
+		if !started {
+			started = true
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			if isAzureFlavor(c) {
+				// Azure 首个分片仅携带 prompt_filter_results
+				_ = sendSSEvent(c, model.OpenAIChatCompletionResponse{
+					ID:                  responseId,
+					Model:               openAIReq.Model,
+					Choices:             []model.OpenAIChoice{},
+					PromptFilterResults: azurePromptFilterResults(),
+				})
+			}
+		}
+		applyAzureFlavor(c, &response, false)
+		return sendSSEvent(c, response)
+	}
+
+	results, err := streamChatChoices(ctx, client, &openAIReq, responseId, send)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		if !started {
+			sendChatUpstreamError(c, err)
+			return
+		}
+		sendStreamError(c, err)
 		return
 	}
-	c.Stream(func(w io.Writer) bool {
-		for attempt := 0; attempt < maxRetries; attempt++ {
-			requestBody, err := createRequestBody(c, &openAIReq)
-			if err != nil {
-				c.JSON(500, gin.H{"error": err.Error()})
-				return false
-			}
+	if openAIReq.StreamOptions != nil && openAIReq.StreamOptions.IncludeUsage {
+		usageResp := createUsageResponse(responseId, openAIReq.Model, chatUsage(&openAIReq, results))
+		if err := sendSSEvent(c, usageResp); err != nil {
+			logger.Warnf(ctx, "sendSSEvent err: %v", err)
+		}
+	}
+	c.SSEvent("", " [DONE]")
+}
 
-			jsonData, err := json.Marshal(requestBody)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "Failed to marshal request body"})
-				return false
+// streamChatChoices 生成各回复并通过 send 逐个发送分片, 处理思考过程、工具调用及结构化输出
+// n > 1 时各回复并行生成, 增量按 index 交错输出, send 调用时已加锁
+func streamChatChoices(ctx context.Context, client cycletls.CycleTLS, openAIReq *model.OpenAIChatCompletionRequest, responseId string, send chunkSender) ([]*upstreamResult, error) {
+	var mu sync.Mutex
+	return doUpstreamChoices(ctx, openAIReq, func(ctx context.Context, index int) (*upstreamResult, error) {
+		// 思考过程按 REASONING_FORMAT 以 reasoning_content 或 <think> 标签输出
+		think := &thinkTagStream{}
+		sendReasoning := func(text string) error {
+			if reasoningAsThinkTag() {
+				return handleDelta(send, index, think.reasoning(text), responseId, openAIReq.Model)
 			}
-			sseChan, err := sourcegraphapi.MakeStreamChatRequest(c, client, jsonData, cookie)
+			return handleReasoningDelta(send, index, text, responseId, openAIReq.Model)
+		}
+		sendContent := func(text string) error {
+			return handleDelta(send, index, think.content(text), responseId, openAIReq.Model)
+		}
+
+		// 要求 JSON 输出时需先校验完整结果, 校验通过后一次性输出
+		if openAIReq.ResponseFormat.IsJSON() {
+			result, err := doStructuredUpstreamChat(ctx, client, openAIReq)
 			if err != nil {
-				logger.Errorf(ctx, "MakeStreamChatRequest err on attempt %d: %v", attempt+1, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return false
+				return nil, err
 			}
-
-			isRateLimit := false
-			thinkStartType := new(bool) // 初始值为false
-		SSELoop:
-			for response := range sseChan {
-
-				if response.Status == 400 {
-					isRateLimit = true
-					logger.Errorf(ctx, fmt.Sprintf("No permission to call this model:%s", openAIReq.Model))
-					break SSELoop // 使用 label 跳出 SSE 循环
-				}
-				if response.Status == 429 {
-					isRateLimit = true
-					logger.Warnf(ctx, "Cookie rate limited, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
-					config.AddRateLimitCookie(cookie, time.Now().Add(time.Duration(config.RateLimitCookieLockDuration)*time.Second))
-					break SSELoop // 使用 label 跳出 SSE 循环
-				}
-
-				if response.Done {
-					logger.Debugf(ctx, response.Data)
-					return false
-				}
-
-				data := response.Data
-				if data == "" {
-					continue
-				}
-
-				logger.Debug(ctx, strings.TrimSpace(data))
-
-				switch {
-				case common.IsCloudflareChallenge(data):
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "cf challenge"})
-					return false
-				case common.IsRateLimit(data):
-					isRateLimit = true
-					logger.Warnf(ctx, "Cookie rate limited, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
-					config.AddRateLimitCookie(cookie, time.Now().Add(time.Duration(config.RateLimitCookieLockDuration)*time.Second))
-					break SSELoop // 使用 label 跳出 SSE 循环
-				case common.IsNotLogin(data):
-					isRateLimit = true
-					logger.Warnf(ctx, "Cookie Not Login, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
-					// 删除cookie
-					//config.RemoveCookie(cookie)
-					break SSELoop // 使用 label 跳出 SSE 循环
+			mu.Lock()
+			defer mu.Unlock()
+			if result.Reasoning != "" && !reasoningHidden() {
+				if err := sendReasoning(result.Reasoning); err != nil {
+					logger.Errorf(ctx, "sendReasoning err: %v", err)
+					return result, nil
 				}
+			}
+			if err := sendContent(result.Content); err != nil {
+				logger.Errorf(ctx, "handleDelta err: %v", err)
+				return result, nil
+			}
+			handleMessageResult(ctx, send, index, responseId, openAIReq.Model, result.finishReason())
+			return result, nil
+		}
 
-				_, shouldContinue := processStreamData(c, data, responseId, openAIReq.Model, jsonData, thinkStartType)
-				// 处理事件流数据
+		// 请求携带 tools 时拦截工具调用标签, 读取到完整调用后即结束上游请求
+		var toolStream *toolCallStream
+		if toolsEnabled(openAIReq) {
+			toolStream = &toolCallStream{}
+		}
 
-				if !shouldContinue {
+		var onReasoning deltaHandler
+		if !reasoningHidden() {
+			onReasoning = func(text string) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				if err := sendReasoning(text); err != nil {
+					logger.Errorf(ctx, "sendReasoning err: %v", err)
 					return false
 				}
+				return true
 			}
+		}
 
-			if !isRateLimit {
-				return true
+		result, err := doUpstreamReasoningChat(ctx, client, openAIReq, onReasoning, func(delta string) bool {
+			complete := false
+			if toolStream != nil {
+				delta, complete = toolStream.feed(delta)
+				if delta == "" {
+					return !complete
+				}
 			}
-			// 获取下一个可用的cookie继续尝试
-			cookie, err = cookieManager.GetNextCookie()
-			if err != nil {
-				logger.Errorf(ctx, "No more valid cookies available after attempt %d", attempt+1)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			mu.Lock()
+			defer mu.Unlock()
+			if err := sendContent(delta); err != nil {
+				logger.Errorf(ctx, "handleDelta err: %v", err)
 				return false
 			}
-
+			return !complete
+		})
+		if err != nil {
+			return nil, err
 		}
 
-		logger.Errorf(ctx, "All cookies exhausted after %d attempts", maxRetries)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "All cookies are temporarily unavailable."})
-		return false
+		mu.Lock()
+		defer mu.Unlock()
+		finishReason := result.finishReason()
+		var toolCalls []model.OpenAIToolCall
+		if toolStream != nil {
+			var rest string
+			rest, toolCalls = toolStream.flush()
+			if rest != "" {
+				_ = sendContent(rest)
+			}
+		}
+		// 仅有思考过程时补全结束标签
+		if think.open {
+			_ = sendContent("")
+		}
+		if len(toolCalls) > 0 {
+			finishReason = "tool_calls"
+			if err := handleToolCallsDelta(send, index, toolCalls, responseId, openAIReq.Model); err != nil {
+				logger.Errorf(ctx, "handleToolCallsDelta err: %v", err)
+				return result, nil
+			}
+		}
+		handleMessageResult(ctx, send, index, responseId, openAIReq.Model, finishReason)
+		return result, nil
 	})
 }
 
-// 处理流式数据的辅助函数，返回bool表示是否继续处理
-func processStreamData(c *gin.Context, data string, responseId, model string, jsonData []byte, thinkStartType *bool) (string, bool) {
-	data = strings.TrimSpace(data)
-	data = strings.TrimPrefix(data, "data: ")
-
-	if data == `{"stopReason":"end_turn"}` {
-		handleMessageResult(c, responseId, model, jsonData)
-		return "", false
-	}
-
-	if !strings.HasPrefix(data, "{\"deltaText\":") {
-		return "", true
-	}
-
-	var event map[string]interface{}
-	if err := json.Unmarshal([]byte(data), &event); err != nil {
-		logger.Errorf(c.Request.Context(), "Failed to unmarshal event: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return "", false
-	}
-	delta, ok := event["deltaText"].(string)
-	if ok {
-		if err := handleDelta(c, delta, responseId, model, jsonData); err != nil {
-			logger.Errorf(c.Request.Context(), "handleDelta err: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return "", false
-		}
-		return delta, true
-	}
-	//delta, ok = event["reasoning_content"].(string)
-	//if ok {
-	//	if !*thinkStartType {
-	//		delta = "<think>\n" + delta
-	//		*thinkStartType = true
-	//	}
-	//	if err := handleDelta(c, delta, responseId, model, jsonData); err != nil {
-	//		logger.Errorf(c.Request.Context(), "handleDelta err: %v", err)
-	//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	//		return "", false
-	//	}
-	//	return delta, true
-	//}
-	//_, ok = event["thinking_time"].(float64)
-	//if ok {
-	//	delta = "\n</think>"
-	//	if err := handleDelta(c, delta, responseId, model, jsonData); err != nil {
-	//		logger.Errorf(c.Request.Context(), "handleDelta err: %v", err)
-	//		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-	//		return "", false
-	//	}
-	//	return delta, true
-	//}
-
-	return "", true
-
+// sendStreamError 流式响应开始后上游失败时发送错误分片及 [DONE], 避免客户端等待或将截断的输出视为完整结果
+func sendStreamError(c *gin.Context, err error) {
+	logger.Errorf(c.Request.Context(), "stream upstream err: %v", err)
+	_ = sendSSEvent(c, model.OpenAIErrorResponse{
+		OpenAIError: model.OpenAIError{
+			Message: err.Error(),
+			Type:    "upstream_error",
+			Code:    strconv.Itoa(upstreamErrorStatus(err)),
+		},
+	})
+	c.SSEvent("", " [DONE]")
 }
 
-func processNoStreamData(c *gin.Context, data string, responseId, model string, jsonData []byte, thinkStartType *bool) (string, bool) {
-	data = strings.TrimSpace(data)
-	data = strings.TrimPrefix(data, "data: ")
-
-	if data == `{"stopReason":"stop"}` {
-		return "", false
+// sendChatError 按接口风格返回错误
+func sendChatError(c *gin.Context, status int, errorType, code, message string) {
+	if isAzureFlavor(c) {
+		sendAzureError(c, status, code, message)
+		return
 	}
+	sendOpenAIError(c, status, errorType, code, message)
+}
 
-	//if !strings.HasPrefix(data, "{\"deltaText\":") {
-	//	return "", true
-	//}
-
-	var event map[string]interface{}
-	if err := json.Unmarshal([]byte(data), &event); err != nil {
-		logger.Errorf(c.Request.Context(), "Failed to unmarshal event: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return "", false
-	}
-	delta, ok := event["deltaText"].(string)
-	if ok {
-		return delta, true
+// sendChatUpstreamError 按接口风格返回上游错误
+func sendChatUpstreamError(c *gin.Context, err error) {
+	if isAzureFlavor(c) {
+		sendAzureError(c, upstreamErrorStatus(err), "upstream_error", err.Error())
+		return
 	}
-	//delta, ok = event["reasoning_content"].(string)
-	//if ok {
-	//	if !*thinkStartType {
-	//		delta = "<think>\n" + delta
-	//		*thinkStartType = true
-	//	}
-	//	return delta, true
-	//}
-	//_, ok = event["thinking_time"].(float64)
-	//if ok {
-	//	delta = "\n</think>"
-	//	return delta, true
-	//}
-
-	return "", false
-
+	c.JSON(upstreamErrorStatus(err), gin.H{"error": err.Error()})
 }
 
 func OpenaiModels(c *gin.Context) {
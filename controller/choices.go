@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sourcegraph2api/common/config"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"sync"
+)
+
+// maxChoices 单次请求最多生成的回复数量
+const maxChoices = 8
+
+// choiceGenerator 生成第 index 个回复
+type choiceGenerator func(ctx context.Context, index int) (*upstreamResult, error)
+
+// doUpstreamChoices 并行生成 n 个回复, 各回复从不同的 cookie 开始请求
+// cookie 少于回复数量时返回 400; 部分回复失败时返回其余回复, 失败的回复为 nil, 全部失败时返回首个错误
+func doUpstreamChoices(ctx context.Context, openAIReq *model.OpenAIChatCompletionRequest, generate choiceGenerator) ([]*upstreamResult, error) {
+	n := openAIReq.ChoiceCount()
+	if n == 1 {
+		result, err := generate(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		return []*upstreamResult{result}, nil
+	}
+
+	// 各回复需要使用不同的 cookie, 数量不足时直接返回错误
+	if available := len(config.NewCookieManager().Cookies); available < n {
+		logger.Warnf(ctx, "Only %d cookies available for %d choices", available, n)
+		return nil, &upstreamError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("n=%d requires at least %d cookies, but only %d are configured", n, n, available)}
+	}
+	// 提前解析图片等内容, 避免并行请求同时修改消息
+	if _, err := createRequestBody(ctx, openAIReq); err != nil {
+		if _, ok := err.(*upstreamError); ok {
+			return nil, err
+		}
+		return nil, &upstreamError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	results := make([]*upstreamResult, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	offset := rand.Intn(maxChoices * 1024)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			choiceCtx := context.WithValue(ctx, cookieOffsetKey{}, offset+index)
+			results[index], errs[index] = generate(choiceCtx, index)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for index, err := range errs {
+		if err != nil {
+			logger.Warnf(ctx, "Choice %d failed: %v", index, err)
+			results[index] = nil
+			failed++
+		}
+	}
+	if failed == n {
+		return nil, errs[0]
+	}
+	return results, nil
+}
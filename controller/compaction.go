@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"sourcegraph2api/common/config"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// historyCompactedHeader 响应头返回被摘要替换的消息数量
+	historyCompactedHeader = "X-History-Compacted-Messages"
+	// compactionMaxTokens 摘要的最大输出 token 数
+	compactionMaxTokens = 2000
+	compactionPrompt    = "You compress chat histories. Summarize the conversation transcript given by the user so that the conversation can continue from the summary alone. " +
+		"Keep every fact, decision, requirement, name, number, code identifier and open question that later turns may rely on. " +
+		"Write in the language of the conversation and output only the summary."
+	compactionSummaryFormat = "Summary of the earlier conversation:\n\n%s"
+	// compactionPreviousFormat 增量压缩时放在新消息前的上一次摘要
+	compactionPreviousFormat = "[summary of the conversation so far]\n%s\n\n"
+)
+
+type compactionEntry struct {
+	summary        string
+	expirationTime time.Time
+}
+
+var (
+	compactionStore sync.Map // 以对话前缀哈希缓存摘要
+)
+
+func saveCompaction(key, summary string) {
+	compactionStore.Store(key, &compactionEntry{
+		summary:        summary,
+		expirationTime: time.Now().Add(time.Duration(config.CompactionCacheDuration) * time.Second),
+	})
+
+	// 清理过期摘要
+	compactionStore.Range(func(key, value interface{}) bool {
+		if e, ok := value.(*compactionEntry); ok && e.expirationTime.Before(time.Now()) {
+			compactionStore.Delete(key)
+		}
+		return true
+	})
+}
+
+func loadCompaction(key string) (string, bool) {
+	value, ok := compactionStore.Load(key)
+	if !ok {
+		return "", false
+	}
+	entry := value.(*compactionEntry)
+	if entry.expirationTime.Before(time.Now()) {
+		compactionStore.Delete(key)
+		return "", false
+	}
+	return entry.summary, true
+}
+
+// compactMessages 对话接口(含 Azure、WebSocket)超出 COMPACTION_THRESHOLD 时, 以 COMPACTION_MODEL 将较早的消息摘要为一条 system 消息,
+// 开头的 system 消息及最后 COMPACTION_KEEP_LAST_N 条左右的消息保留, 返回被替换的消息数量
+// 压缩边界按保留数对齐, 对话增长时前缀不变, 摘要按前缀哈希缓存并在此基础上增量压缩
+func compactMessages(ctx context.Context, client cycletls.CycleTLS, req *model.OpenAIChatCompletionRequest) (int, error) {
+	if config.CompactionThreshold <= 0 || model.CountTokenMessages(req.Messages, req.Model) <= config.CompactionThreshold {
+		return 0, nil
+	}
+
+	var system []model.OpenAIChatMessage
+	conversation := req.Messages
+	for len(conversation) > 0 && (conversation[0].Role == "system" || conversation[0].Role == "developer") {
+		system = append(system, conversation[0])
+		conversation = conversation[1:]
+	}
+	step := config.CompactionKeepLastN
+	if step < 1 {
+		step = 1
+	}
+	aligned := (len(conversation) - step) / step * step
+	cut := compactionCut(conversation, aligned)
+	if cut <= 0 {
+		return 0, nil
+	}
+
+	key := compactionKey(conversation[:cut])
+	summary, ok := loadCompaction(key)
+	if !ok {
+		// 查找已缓存的较短前缀, 只摘要其后的消息
+		previous, start := "", 0
+		for prev := aligned - step; prev > 0; prev -= step {
+			prevCut := compactionCut(conversation, prev)
+			if prevCut <= 0 || prevCut >= cut {
+				continue
+			}
+			if s, found := loadCompaction(compactionKey(conversation[:prevCut])); found {
+				previous, start = s, prevCut
+				break
+			}
+		}
+
+		var err error
+		summary, err = summarizeMessages(ctx, client, previous, conversation[start:cut])
+		if err != nil {
+			return 0, err
+		}
+		saveCompaction(key, summary)
+	}
+
+	messages := append([]model.OpenAIChatMessage(nil), system...)
+	messages = append(messages, model.OpenAIChatMessage{Role: "system", Content: fmt.Sprintf(compactionSummaryFormat, summary)})
+	req.Messages = append(messages, conversation[cut:]...)
+	return cut, nil
+}
+
+// compactionCut 自 end 向前查找 user 消息作为压缩边界, 保证保留的消息从 user 消息开始, 不拆开工具调用与结果
+func compactionCut(conversation []model.OpenAIChatMessage, end int) int {
+	for end > 0 && conversation[end].Role != "user" {
+		end--
+	}
+	return end
+}
+
+// compactionKey 被压缩消息与摘要模型的哈希
+func compactionKey(messages []model.OpenAIChatMessage) string {
+	data, _ := json.Marshal(messages)
+	sum := sha256.Sum256(append([]byte(config.CompactionModel+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// summarizeMessages 通过上游以摘要模型生成摘要, previous 不为空时与新消息合并为一份摘要
+func summarizeMessages(ctx context.Context, client cycletls.CycleTLS, previous string, messages []model.OpenAIChatMessage) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString(fmt.Sprintf(compactionPreviousFormat, previous))
+	}
+	for _, msg := range messages {
+		msg.Content = messageText(msg.Content)
+		if msg.Role == "function" {
+			msg.Role = "tool"
+		}
+		transcript.WriteString(fmt.Sprintf("[%s]\n%s\n\n", msg.Role, toolMessageText(msg)))
+	}
+
+	summaryReq := model.OpenAIChatCompletionRequest{
+		Model:     config.CompactionModel,
+		MaxTokens: compactionMaxTokens,
+		Messages: []model.OpenAIChatMessage{
+			{Role: "system", Content: compactionPrompt},
+			{Role: "user", Content: transcript.String()},
+		},
+	}
+	result, err := doUpstreamChat(ctx, client, &summaryReq, nil)
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(result.Content)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary from %s", config.CompactionModel)
+	}
+	logger.Infof(ctx, "Compacted %d messages into %d tokens", len(messages), model.CountTokenText(summary, config.CompactionModel))
+	return summary, nil
+}
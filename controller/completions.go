@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sourcegraph2api/common"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"time"
+)
+
+const (
+	completionIDFormat = "cmpl-%s"
+	// completionInsertPrompt 请求携带 suffix 时要求模型补全 prefix 与 suffix 之间的文本
+	completionInsertPrompt = "Continue the text between <prefix> and <suffix>. Reply with the inserted text only.\n\n<prefix>%s</prefix>\n<suffix>%s</suffix>"
+)
+
+// CompletionsForOpenAI @Summary OpenAI文本补全接口
+// @Description OpenAI 旧版文本补全接口, prompt 作为一轮 human 对话发送
+// @Tags OpenAI
+// @Accept json
+// @Produce json
+// @Param req body model.OpenAICompletionRequest true "OpenAI文本补全请求"
+// @Param Authorization header string true "Authorization API-KEY"
+// @Router /v1/completions [post]
+func CompletionsForOpenAI(c *gin.Context) {
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	var completionReq model.OpenAICompletionRequest
+	if err := c.BindJSON(&completionReq); err != nil {
+		logger.Errorf(c.Request.Context(), err.Error())
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_request", "Invalid request parameters")
+		return
+	}
+	modelInfo, b := common.GetSGModelInfo(completionReq.Model)
+	if !b {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_model", fmt.Sprintf("Model %s not supported", completionReq.Model))
+		return
+	}
+	if completionReq.MaxTokens > modelInfo.MaxTokens {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_max_tokens", fmt.Sprintf("Max tokens %d exceeds limit %d", completionReq.MaxTokens, modelInfo.MaxTokens))
+		return
+	}
+	if len(completionReq.StopSequences()) > maxStopSequences {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_stop", fmt.Sprintf("stop accepts at most %d sequences", maxStopSequences))
+		return
+	}
+	if len(completionReq.Prompts()) == 0 {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_prompt", "prompt must be a string or an array of strings")
+		return
+	}
+	for _, prompt := range completionReq.Prompts() {
+		openAIReq := completionReq.ToOpenAIRequest(completionPrompt(&completionReq, prompt))
+		if err := checkContextWindow(&openAIReq, modelInfo); err != nil {
+			sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded", err.Error())
+			return
+		}
+	}
+
+	if completionReq.Stream {
+		handleCompletionStreamRequest(c, client, completionReq)
+	} else {
+		handleCompletionNonStreamRequest(c, client, completionReq)
+	}
+}
+
+func handleCompletionNonStreamRequest(c *gin.Context, client cycletls.CycleTLS, completionReq model.OpenAICompletionRequest) {
+	response := model.OpenAICompletionResponse{
+		ID:      fmt.Sprintf(completionIDFormat, common.GetUUID()),
+		Object:  "text_completion",
+		Created: time.Now().Unix(),
+		Model:   completionReq.Model,
+		Usage:   &model.OpenAIUsage{},
+	}
+
+	for index, prompt := range completionReq.Prompts() {
+		openAIReq := completionReq.ToOpenAIRequest(completionPrompt(&completionReq, prompt))
+
+		result, err := doUpstreamChat(c.Request.Context(), client, &openAIReq, nil)
+		if err != nil {
+			sendOpenAIError(c, upstreamErrorStatus(err), "api_error", "upstream_error", err.Error())
+			return
+		}
+
+		text := result.Content
+		finishReason := result.finishReason()
+		promptTokens := model.CountTokenText(prompt, completionReq.Model)
+		completionTokens := model.CountTokenText(text, completionReq.Model)
+		if completionReq.Echo {
+			text = prompt + text
+		}
+		response.Choices = append(response.Choices, model.OpenAICompletionChoice{
+			Text:         text,
+			Index:        index,
+			FinishReason: &finishReason,
+		})
+		response.Usage.PromptTokens += promptTokens
+		response.Usage.CompletionTokens += completionTokens
+		response.Usage.TotalTokens += promptTokens + completionTokens
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func handleCompletionStreamRequest(c *gin.Context, client cycletls.CycleTLS, completionReq model.OpenAICompletionRequest) {
+	responseId := fmt.Sprintf(completionIDFormat, common.GetUUID())
+	created := time.Now().Unix()
+	createChunk := func(index int, text string, finishReason *string) model.OpenAICompletionResponse {
+		return model.OpenAICompletionResponse{
+			ID:      responseId,
+			Object:  "text_completion",
+			Created: created,
+			Model:   completionReq.Model,
+			Choices: []model.OpenAICompletionChoice{{
+				Text:         text,
+				Index:        index,
+				FinishReason: finishReason,
+			}},
+		}
+	}
+
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+	}
+
+	for index, prompt := range completionReq.Prompts() {
+		openAIReq := completionReq.ToOpenAIRequest(completionPrompt(&completionReq, prompt))
+
+		echoed := !completionReq.Echo
+		echo := func() {
+			if !echoed {
+				echoed = true
+				_ = sendSSEvent(c, createChunk(index, prompt, nil))
+			}
+		}
+		result, err := doUpstreamChat(c.Request.Context(), client, &openAIReq, func(delta string) bool {
+			start()
+			echo()
+			_ = sendSSEvent(c, createChunk(index, delta, nil))
+			return true
+		})
+		if err != nil {
+			if !started {
+				sendOpenAIError(c, upstreamErrorStatus(err), "api_error", "upstream_error", err.Error())
+				return
+			}
+			sendStreamError(c, err)
+			return
+		}
+		start()
+		echo()
+
+		finishReason := result.finishReason()
+		_ = sendSSEvent(c, createChunk(index, "", &finishReason))
+	}
+	c.SSEvent("", " [DONE]")
+}
+
+// completionPrompt 生成发送给模型的内容, 携带 suffix 时模拟插入补全
+func completionPrompt(completionReq *model.OpenAICompletionRequest, prompt string) string {
+	if completionReq.Suffix == "" {
+		return prompt
+	}
+	return fmt.Sprintf(completionInsertPrompt, prompt, completionReq.Suffix)
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"fmt"
+	"sourcegraph2api/common"
+	"sourcegraph2api/common/config"
+	"sourcegraph2api/model"
+)
+
+const (
+	truncationNone       = "none"
+	truncationDropOldest = "drop_oldest"
+	truncationKeepLastN  = "keep_last_n"
+	truncationMiddleOut  = "middle_out"
+
+	// contextTruncationHeader 请求头指定本次请求的裁剪策略, 响应头返回实际应用的策略
+	contextTruncationHeader = "X-Context-Truncation"
+	// contextTruncatedHeader 响应头返回被删除的消息数量
+	contextTruncatedHeader = "X-Context-Truncated-Messages"
+	// defaultOutputTokens 未指定 max_tokens 时为输出预留的 token 数, 与 createRequestBody 的默认值一致
+	defaultOutputTokens = 4000
+)
+
+// contextLengthError 裁剪后仍超出上下文窗口
+type contextLengthError struct {
+	Window       int
+	PromptTokens int
+	OutputTokens int
+}
+
+func (e *contextLengthError) Error() string {
+	return fmt.Sprintf("This model's maximum context length is %d tokens. However, you requested %d tokens (%d in the messages, %d in the completion). Please reduce the length of the messages or completion.",
+		e.Window, e.PromptTokens+e.OutputTokens, e.PromptTokens, e.OutputTokens)
+}
+
+// isTruncationStrategy 是否为支持的裁剪策略
+func isTruncationStrategy(strategy string) bool {
+	switch strategy {
+	case truncationNone, truncationDropOldest, truncationKeepLastN, truncationMiddleOut:
+		return true
+	}
+	return false
+}
+
+// truncateMessages 对话超出上下文窗口(扣除输出预留及注入的提示词)时按策略删除历史消息, 开头的 system 消息及最后一条消息始终保留
+// 助手的工具调用与其后的工具结果一同删除, 返回被删除的消息数量, 仍超出上下文窗口时返回 contextLengthError
+func truncateMessages(req *model.OpenAIChatCompletionRequest, modelInfo common.SGModelInfo, strategy string) (int, error) {
+	outputTokens := req.MaxTokens
+	if outputTokens <= 1 {
+		outputTokens = defaultOutputTokens
+	}
+	// 模拟工具调用及 response_format 注入的提示词同样占用上下文
+	tokens := 3
+	for _, prompt := range systemPrompts(req) {
+		tokens += model.CountTokenText(prompt, req.Model)
+	}
+	limit := modelInfo.ContextWindow - outputTokens
+
+	// 每条消息只计算一次, 删除时减去对应的 token 数
+	var system []model.OpenAIChatMessage
+	conversation := req.Messages
+	for len(conversation) > 0 && (conversation[0].Role == "system" || conversation[0].Role == "developer") {
+		tokens += model.CountTokenMessage(conversation[0], req.Model)
+		system = append(system, conversation[0])
+		conversation = conversation[1:]
+	}
+	groups := groupMessages(conversation, req.Model)
+	for _, group := range groups {
+		tokens += group.tokens
+	}
+	if tokens <= limit {
+		return 0, nil
+	}
+	if strategy == truncationNone {
+		return 0, &contextLengthError{Window: modelInfo.ContextWindow, PromptTokens: tokens, OutputTokens: outputTokens}
+	}
+
+	kept := len(conversation)
+	drop := func(index int) {
+		tokens -= groups[index].tokens
+		kept -= len(groups[index].messages)
+		groups = append(groups[:index], groups[index+1:]...)
+	}
+	// trimStart 删除开头没有对应调用的工具结果及 assistant 消息, 使对话从 user 消息开始, 最后一组消息始终保留
+	trimStart := func() {
+		for len(groups) > 1 && groups[0].messages[0].Role != "user" {
+			drop(0)
+		}
+	}
+
+	if strategy == truncationKeepLastN {
+		// 以组为单位删除, 保留的消息可能少于 N 条
+		for len(groups) > 1 && kept > config.ContextKeepLastN {
+			drop(0)
+		}
+		trimStart()
+	}
+	for len(groups) > 1 && tokens > limit {
+		if strategy == truncationMiddleOut && len(groups) > 2 {
+			// 保留第一组及最后一组消息, 删除中间的消息
+			drop(len(groups) / 2)
+			continue
+		}
+		drop(0)
+		trimStart()
+	}
+
+	req.Messages = system
+	for _, group := range groups {
+		req.Messages = append(req.Messages, group.messages...)
+	}
+	if tokens > limit {
+		return len(conversation) - kept, &contextLengthError{Window: modelInfo.ContextWindow, PromptTokens: tokens, OutputTokens: outputTokens}
+	}
+	return len(conversation) - kept, nil
+}
+
+// messageGroup 裁剪时一同删除的消息, 助手的工具调用与其后的工具结果为一组
+type messageGroup struct {
+	messages []model.OpenAIChatMessage
+	tokens   int
+}
+
+// groupMessages 将消息按工具调用分组并计算各组的 token 数
+func groupMessages(messages []model.OpenAIChatMessage, modelName string) []messageGroup {
+	var groups []messageGroup
+	for _, msg := range messages {
+		tokens := model.CountTokenMessage(msg, modelName)
+		last := len(groups) - 1
+		if last >= 0 && (msg.Role == "tool" || msg.Role == "function") && len(groups[last].messages[0].ToolCalls) > 0 {
+			groups[last].messages = append(groups[last].messages, msg)
+			groups[last].tokens += tokens
+			continue
+		}
+		groups = append(groups, messageGroup{messages: []model.OpenAIChatMessage{msg}, tokens: tokens})
+	}
+	return groups
+}
+
+// checkContextWindow 校验 max_tokens 并按 CONTEXT_TRUNCATION 裁剪对话, 未经 prepareChatRequest 的接口在请求上游前调用
+func checkContextWindow(req *model.OpenAIChatCompletionRequest, modelInfo common.SGModelInfo) error {
+	if req.MaxTokens > modelInfo.MaxTokens {
+		return fmt.Errorf("Max tokens %d exceeds limit %d", req.MaxTokens, modelInfo.MaxTokens)
+	}
+	if req.MaxTokens >= modelInfo.ContextWindow {
+		return fmt.Errorf("Max tokens %d exceeds the context window %d of model %s", req.MaxTokens, modelInfo.ContextWindow, req.Model)
+	}
+	_, err := truncateMessages(req, modelInfo, config.ContextTruncation)
+	return err
+}
+
+// fitContextWindow 返回按 CONTEXT_TRUNCATION 裁剪后的请求副本, 各接口共用, 每轮上游请求前调用一次
+// 自动续写及结构化输出重试会追加消息, 需要重新检查
+func fitContextWindow(req *model.OpenAIChatCompletionRequest) (*model.OpenAIChatCompletionRequest, error) {
+	modelInfo, ok := common.GetSGModelInfo(req.Model)
+	if !ok {
+		return req, nil
+	}
+	truncated := *req
+	truncated.Messages = append([]model.OpenAIChatMessage(nil), req.Messages...)
+	if _, err := truncateMessages(&truncated, modelInfo, config.ContextTruncation); err != nil {
+		return nil, err
+	}
+	return &truncated, nil
+}
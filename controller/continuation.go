@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"context"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"sourcegraph2api/common/config"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+)
+
+// autoContinueHeader 开启自动续写的请求头
+const autoContinueHeader = "X-Auto-Continue"
+
+// doContinuation 回复因 max_tokens 截断时, 将已生成的内容作为 assistant 预填充继续请求, 直到正常结束、
+// 达到 AUTO_CONTINUE_MAX_ROUNDS 次或累计输出达到 AUTO_CONTINUE_MAX_TOKENS, 续写失败时返回已生成的内容
+func doContinuation(ctx context.Context, client cycletls.CycleTLS, openAIReq *model.OpenAIChatCompletionRequest, result *upstreamResult, onReasoning, onDelta deltaHandler) *upstreamResult {
+	for round := 1; round <= config.AutoContinueMaxRounds && result.finishReason() == "length"; round++ {
+		if model.CountTokenText(result.Content, openAIReq.Model) >= config.AutoContinueMaxTokens {
+			logger.Warnf(ctx, "Auto continue stopped, output reached %d tokens", config.AutoContinueMaxTokens)
+			break
+		}
+
+		next := continuationRequest(openAIReq, result.Content)
+		logger.Infof(ctx, "Auto continue round %d/%d", round, config.AutoContinueMaxRounds)
+		more, err := doUpstreamRound(ctx, client, next, onReasoning, onDelta)
+		if err != nil {
+			logger.Warnf(ctx, "Auto continue round %d err: %v", round, err)
+			break
+		}
+
+		result.Content += more.Content
+		result.Reasoning += more.Reasoning
+		result.StopReason = more.StopReason
+		result.StopSequence = more.StopSequence
+		if result.Usage != nil && more.Usage != nil {
+			result.Usage.CompletionTokens += more.Usage.CompletionTokens
+		} else {
+			// 部分轮次没有上游用量时改为本地计算
+			result.Usage = nil
+		}
+		if more.Content == "" {
+			break
+		}
+	}
+	return result
+}
+
+// continuationRequest 以已生成的内容作为 assistant 预填充构造续写请求, 请求本身带预填充时拼接在其后
+func continuationRequest(openAIReq *model.OpenAIChatCompletionRequest, content string) *model.OpenAIChatCompletionRequest {
+	next := *openAIReq
+	next.Messages = append([]model.OpenAIChatMessage(nil), openAIReq.Messages...)
+	if prefill, ok := assistantPrefill(openAIReq); ok {
+		next.Messages[len(next.Messages)-1] = model.OpenAIChatMessage{Role: "assistant", Content: prefill + content}
+		return &next
+	}
+	next.Messages = append(next.Messages, model.OpenAIChatMessage{Role: "assistant", Content: content})
+	return &next
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sourcegraph2api/common"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"strings"
+)
+
+// GenerateContentForGemini @Summary Gemini对话接口
+// @Description Gemini generateContent / streamGenerateContent 对话接口
+// @Tags Gemini
+// @Accept json
+// @Produce json
+// @Param action path string true "模型及方法, 如 gemini-2.0-flash:generateContent"
+// @Param req body model.GeminiGenerateContentRequest true "Gemini对话请求"
+// @Param key query string false "API-KEY"
+// @Router /v1beta/models/{action} [post]
+func GenerateContentForGemini(c *gin.Context) {
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	action := strings.TrimPrefix(c.Param("action"), "/")
+	index := strings.LastIndex(action, ":")
+	if index < 0 {
+		sendGeminiError(c, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Unknown action %s", action))
+		return
+	}
+	modelName, method := strings.TrimPrefix(action[:index], "models/"), action[index+1:]
+	if method != "generateContent" && method != "streamGenerateContent" {
+		sendGeminiError(c, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Unknown method %s", method))
+		return
+	}
+
+	var geminiReq model.GeminiGenerateContentRequest
+	if err := c.BindJSON(&geminiReq); err != nil {
+		logger.Errorf(c.Request.Context(), err.Error())
+		sendGeminiError(c, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid request parameters")
+		return
+	}
+	modelInfo, b := common.GetSGModelInfo(modelName)
+	if !b {
+		sendGeminiError(c, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Model %s not supported", modelName))
+		return
+	}
+
+	openAIReq, err := geminiReq.ToOpenAIRequest(modelName, method == "streamGenerateContent")
+	if err != nil {
+		sendGeminiError(c, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if openAIReq.MaxTokens > modelInfo.MaxTokens {
+		sendGeminiError(c, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf("Max tokens %d exceeds limit %d", openAIReq.MaxTokens, modelInfo.MaxTokens))
+		return
+	}
+	if !common.IsVisionModel(openAIReq.Model) && hasImageContent(openAIReq.Messages) {
+		sendGeminiError(c, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf(imageUnsupportedMsg, openAIReq.Model))
+		return
+	}
+	openAIReq.RemoveEmptyContentMessages()
+	if err := checkContextWindow(&openAIReq, modelInfo); err != nil {
+		sendGeminiError(c, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	if openAIReq.Stream {
+		handleGeminiStreamRequest(c, client, openAIReq)
+	} else {
+		handleGeminiNonStreamRequest(c, client, openAIReq)
+	}
+}
+
+func handleGeminiNonStreamRequest(c *gin.Context, client cycletls.CycleTLS, openAIReq model.OpenAIChatCompletionRequest) {
+	result, err := doUpstreamChat(c.Request.Context(), client, &openAIReq, nil)
+	if err != nil {
+		sendGeminiError(c, upstreamErrorStatus(err), "INTERNAL", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, createGeminiResponse(openAIReq.Model, result.Content, geminiFinishReason(result), createGeminiUsage(openAIReq, result.Content)))
+}
+
+func handleGeminiStreamRequest(c *gin.Context, client cycletls.CycleTLS, openAIReq model.OpenAIChatCompletionRequest) {
+	// 未指定 alt=sse 时按 Gemini 约定在结束后返回 JSON 数组
+	isSSE := c.Query("alt") == "sse"
+	var chunks []model.GeminiGenerateContentResponse
+	started := false
+	emit := func(chunk model.GeminiGenerateContentResponse) {
+		if !isSSE {
+			chunks = append(chunks, chunk)
+			return
+		}
+		if !started {
+			started = true
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+		}
+		sendGeminiEvent(c, chunk)
+	}
+
+	usage := createGeminiUsage(openAIReq, "")
+	result, err := doUpstreamChat(c.Request.Context(), client, &openAIReq, func(delta string) bool {
+		emit(createGeminiResponse(openAIReq.Model, delta, "", usage))
+		return true
+	})
+	if err != nil {
+		if !started {
+			sendGeminiError(c, upstreamErrorStatus(err), "INTERNAL", err.Error())
+			return
+		}
+		sendGeminiEvent(c, model.GeminiErrorResponse{
+			Error: model.GeminiError{Code: upstreamErrorStatus(err), Message: err.Error(), Status: "INTERNAL"},
+		})
+		return
+	}
+	emit(createGeminiResponse(openAIReq.Model, "", geminiFinishReason(result), createGeminiUsage(openAIReq, result.Content)))
+
+	if !isSSE {
+		c.JSON(http.StatusOK, chunks)
+	}
+}
+
+// createGeminiResponse 创建 Gemini 响应
+func createGeminiResponse(modelName, text, finishReason string, usage model.GeminiUsageMetadata) model.GeminiGenerateContentResponse {
+	return model.GeminiGenerateContentResponse{
+		Candidates: []model.GeminiCandidate{{
+			Content: model.GeminiContent{
+				Role:  "model",
+				Parts: []model.GeminiPart{{Text: text}},
+			},
+			FinishReason: finishReason,
+			Index:        0,
+		}},
+		UsageMetadata: usage,
+		ModelVersion:  modelName,
+	}
+}
+
+// createGeminiUsage 统计 token 用量
+func createGeminiUsage(openAIReq model.OpenAIChatCompletionRequest, content string) model.GeminiUsageMetadata {
+	promptTokens := model.CountTokenMessages(openAIReq.Messages, openAIReq.Model)
+	completionTokens := model.CountTokenText(content, openAIReq.Model)
+	return model.GeminiUsageMetadata{
+		PromptTokenCount:     promptTokens,
+		CandidatesTokenCount: completionTokens,
+		TotalTokenCount:      promptTokens + completionTokens,
+	}
+}
+
+// geminiFinishReason 将上游结束原因转换为 Gemini finishReason
+func geminiFinishReason(result *upstreamResult) string {
+	switch result.finishReason() {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	}
+	return "STOP"
+}
+
+// sendGeminiEvent 发送 Gemini SSE 事件
+func sendGeminiEvent(c *gin.Context, event interface{}) {
+	jsonResp, err := json.Marshal(event)
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "Failed to marshal response: %v", err)
+		return
+	}
+	c.SSEvent("", " "+string(jsonResp))
+	c.Writer.Flush()
+}
+
+func sendGeminiError(c *gin.Context, status int, errorStatus, message string) {
+	c.JSON(status, model.GeminiErrorResponse{
+		Error: model.GeminiError{
+			Code:    status,
+			Message: message,
+			Status:  errorStatus,
+		},
+	})
+}
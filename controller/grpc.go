@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"net/http"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"sourcegraph2api/proto/chatpb"
+	"time"
+)
+
+// GrpcChatServer gRPC 对话服务, 与 HTTP 对话接口共用上游请求及 cookie 重试逻辑
+type GrpcChatServer struct {
+	chatpb.UnimplementedChatServer
+}
+
+// CreateChatCompletion 非流式对话
+func (s *GrpcChatServer) CreateChatCompletion(ctx context.Context, req *chatpb.ChatCompletionRequest) (*chatpb.ChatCompletionResponse, error) {
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	openAIReq, err := grpcToOpenAIRequest(ctx, client, req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := createChatCompletion(ctx, client, &openAIReq)
+	if err != nil {
+		return nil, grpcUpstreamError(err)
+	}
+	return grpcFromOpenAIResponse(response), nil
+}
+
+// StreamChatCompletion 流式对话, 最后一条消息携带用量
+func (s *GrpcChatServer) StreamChatCompletion(req *chatpb.ChatCompletionRequest, stream chatpb.Chat_StreamChatCompletionServer) error {
+	ctx := stream.Context()
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	openAIReq, err := grpcToOpenAIRequest(ctx, client, req, true)
+	if err != nil {
+		return err
+	}
+
+	responseId := fmt.Sprintf(responseIDFormat, time.Now().Format("20060102150405"))
+	var sendErr error
+	results, err := streamChatChoices(ctx, client, &openAIReq, responseId, func(response model.OpenAIChatCompletionResponse) error {
+		if sendErr = stream.Send(grpcFromOpenAIResponse(response)); sendErr != nil {
+			logger.Warnf(ctx, "grpc stream send err: %v", sendErr)
+		}
+		return sendErr
+	})
+	if err != nil {
+		return grpcUpstreamError(err)
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	return stream.Send(grpcFromOpenAIResponse(createUsageResponse(responseId, openAIReq.Model, chatUsage(&openAIReq, results))))
+}
+
+// grpcToOpenAIRequest 转换 gRPC 请求, 按 HTTP 对话接口相同的流程校验, 压缩及裁剪信息以 header metadata 返回
+func grpcToOpenAIRequest(ctx context.Context, client cycletls.CycleTLS, req *chatpb.ChatCompletionRequest, stream bool) (model.OpenAIChatCompletionRequest, error) {
+	openAIReq := model.OpenAIChatCompletionRequest{
+		Model:           req.GetModel(),
+		Stream:          stream,
+		MaxTokens:       int(req.GetMaxTokens()),
+		Temperature:     req.GetTemperature(),
+		N:               int(req.GetN()),
+		ReasoningEffort: req.GetReasoningEffort(),
+	}
+	if stops := req.GetStop(); len(stops) > 0 {
+		openAIReq.Stop = stops
+	}
+	for _, msg := range req.GetMessages() {
+		openAIReq.Messages = append(openAIReq.Messages, model.OpenAIChatMessage{
+			Role:       msg.GetRole(),
+			Content:    msg.GetContent(),
+			Name:       msg.GetName(),
+			ToolCalls:  grpcToOpenAIToolCalls(msg.GetToolCalls()),
+			ToolCallID: msg.GetToolCallId(),
+		})
+	}
+	for _, tool := range req.GetTools() {
+		openAIReq.Tools = append(openAIReq.Tools, model.OpenAITool{
+			Type: tool.GetType(),
+			Function: model.OpenAIFunction{
+				Name:        tool.GetFunction().GetName(),
+				Description: tool.GetFunction().GetDescription(),
+				Parameters:  grpcStructValue(tool.GetFunction().GetParameters()),
+			},
+		})
+	}
+	switch choice := req.GetToolChoice().GetChoice().(type) {
+	case *chatpb.ToolChoice_Mode:
+		openAIReq.ToolChoice = choice.Mode
+	case *chatpb.ToolChoice_FunctionName:
+		openAIReq.ToolChoice = map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": choice.FunctionName}}
+	}
+	if format := req.GetResponseFormat(); format != nil {
+		openAIReq.ResponseFormat = &model.OpenAIResponseFormat{Type: format.GetType()}
+		if schema := format.GetJsonSchema(); schema != nil {
+			openAIReq.ResponseFormat.JSONSchema = &model.OpenAIJSONSchema{
+				Name:        schema.GetName(),
+				Description: schema.GetDescription(),
+				Schema:      grpcStructValue(schema.GetSchema()),
+				Strict:      schema.Strict,
+			}
+		}
+	}
+
+	headers, reqErr := prepareChatRequest(ctx, client, &openAIReq, chatRequestOptions{})
+	if reqErr != nil {
+		return openAIReq, status.Error(codes.InvalidArgument, reqErr.Message)
+	}
+	if len(headers) > 0 {
+		if err := grpc.SetHeader(ctx, metadata.New(headers)); err != nil {
+			logger.Warnf(ctx, "grpc set header err: %v", err)
+		}
+	}
+	return openAIReq, nil
+}
+
+// grpcStructValue 将 Struct 转换为 JSON 对象, 未设置时返回 nil
+func grpcStructValue(value *structpb.Struct) interface{} {
+	if value == nil {
+		return nil
+	}
+	return value.AsMap()
+}
+
+func grpcToOpenAIToolCalls(toolCalls []*chatpb.ToolCall) []model.OpenAIToolCall {
+	var calls []model.OpenAIToolCall
+	for _, call := range toolCalls {
+		calls = append(calls, model.OpenAIToolCall{
+			ID:       call.GetId(),
+			Type:     call.GetType(),
+			Function: model.OpenAIFunctionCall{Name: call.GetFunction().GetName(), Arguments: call.GetFunction().GetArguments()},
+		})
+	}
+	return calls
+}
+
+func grpcFromOpenAIToolCalls(toolCalls []model.OpenAIToolCall) []*chatpb.ToolCall {
+	var calls []*chatpb.ToolCall
+	for _, call := range toolCalls {
+		pbCall := &chatpb.ToolCall{
+			Id:       call.ID,
+			Type:     call.Type,
+			Function: &chatpb.FunctionCall{Name: call.Function.Name, Arguments: call.Function.Arguments},
+		}
+		if call.Index != nil {
+			index := int32(*call.Index)
+			pbCall.Index = &index
+		}
+		calls = append(calls, pbCall)
+	}
+	return calls
+}
+
+// grpcFromOpenAIResponse 转换为 gRPC 响应
+func grpcFromOpenAIResponse(response model.OpenAIChatCompletionResponse) *chatpb.ChatCompletionResponse {
+	resp := &chatpb.ChatCompletionResponse{
+		Id:      response.ID,
+		Object:  response.Object,
+		Created: response.Created,
+		Model:   response.Model,
+	}
+	if response.Usage != nil {
+		resp.Usage = &chatpb.Usage{
+			PromptTokens:     int32(response.Usage.PromptTokens),
+			CompletionTokens: int32(response.Usage.CompletionTokens),
+			TotalTokens:      int32(response.Usage.TotalTokens),
+		}
+	}
+	for _, choice := range response.Choices {
+		pbChoice := &chatpb.ChatChoice{
+			Index:        int32(choice.Index),
+			FinishReason: choice.FinishReason,
+		}
+		if response.Object == "chat.completion.chunk" {
+			pbChoice.Delta = &chatpb.ChatMessage{
+				Role:             choice.Delta.Role,
+				Content:          choice.Delta.Content,
+				ToolCalls:        grpcFromOpenAIToolCalls(choice.Delta.ToolCalls),
+				ReasoningContent: choice.Delta.ReasoningContent,
+			}
+		} else {
+			pbChoice.Message = &chatpb.ChatMessage{
+				Role:             choice.Message.Role,
+				Content:          choice.Message.Content,
+				ToolCalls:        grpcFromOpenAIToolCalls(choice.Message.ToolCalls),
+				ReasoningContent: choice.Message.ReasoningContent,
+			}
+		}
+		resp.Choices = append(resp.Choices, pbChoice)
+	}
+	return resp
+}
+
+// grpcUpstreamError 将上游错误转换为 gRPC 状态码
+func grpcUpstreamError(err error) error {
+	code := codes.Internal
+	switch upstreamErrorStatus(err) {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case statusClientClosedRequest:
+		code = codes.Canceled
+	}
+	return status.Error(code, err.Error())
+}
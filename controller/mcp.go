@@ -0,0 +1,367 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"sort"
+	"sourcegraph2api/common"
+	"sourcegraph2api/common/helper"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"strings"
+	"sync"
+)
+
+const (
+	mcpServerName      = "sourcegraph2api"
+	mcpProtocolVersion = "2025-03-26"
+
+	mcpParseError     = -32700
+	mcpInvalidRequest = -32600
+	mcpMethodNotFound = -32601
+	mcpInvalidParams  = -32602
+)
+
+var mcpSupportedVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// mcpSessions SSE 传输的会话, sessionId -> *mcpSSESession
+var mcpSessions sync.Map
+
+// mcpSession MCP 会话, 记录进行中的请求以便响应 notifications/cancelled
+type mcpSession struct {
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+}
+
+type mcpSSESession struct {
+	*mcpSession
+	ctx       context.Context
+	responses chan model.MCPResponse
+}
+
+func newMCPSession() *mcpSession {
+	return &mcpSession{inflight: make(map[string]context.CancelFunc)}
+}
+
+// ServeMCPStdio 以 stdio 传输运行 MCP 服务, 每行一条 JSON-RPC 消息, 直至 r 关闭
+func ServeMCPStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	session := newMCPSession()
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	write := func(resp *model.MCPResponse) {
+		jsonResp, err := json.Marshal(resp)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to marshal response: %v", err)
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, _ = w.Write(append(jsonResp, '\n'))
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var req model.MCPRequest
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			write(mcpErrorResponse(nil, mcpParseError, "Parse error"))
+			continue
+		}
+		reqCtx := context.WithValue(ctx, helper.RequestIdKey, helper.GenRequestID())
+		if req.Method != "tools/call" {
+			if resp := session.handle(reqCtx, req); resp != nil {
+				write(resp)
+			}
+			continue
+		}
+		// 工具调用耗时较长, 单独处理以便继续接收其他消息及取消通知
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp := session.handle(reqCtx, req); resp != nil {
+				write(resp)
+			}
+		}()
+	}
+	wg.Wait()
+	return scanner.Err()
+}
+
+// MCPForHTTP @Summary MCP接口(Streamable HTTP)
+// @Description MCP Streamable HTTP 传输, 请求体为 JSON-RPC 消息, 直接以 JSON 返回结果
+// @Tags MCP
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Authorization API-KEY"
+// @Router /mcp [post]
+func MCPForHTTP(c *gin.Context) {
+	var req model.MCPRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, mcpErrorResponse(nil, mcpParseError, "Parse error"))
+		return
+	}
+	resp := newMCPSession().handle(c.Request.Context(), req)
+	if resp == nil {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// MCPSSEForHTTP @Summary MCP接口(SSE)
+// @Description MCP HTTP+SSE 传输, 首个 endpoint 事件返回消息提交地址, 结果以 message 事件推送
+// @Tags MCP
+// @Produce text/event-stream
+// @Param Authorization header string true "Authorization API-KEY"
+// @Router /mcp/sse [get]
+func MCPSSEForHTTP(c *gin.Context) {
+	sessionId := common.GetUUID()
+	session := &mcpSSESession{
+		mcpSession: newMCPSession(),
+		ctx:        c.Request.Context(),
+		responses:  make(chan model.MCPResponse),
+	}
+	mcpSessions.Store(sessionId, session)
+	defer mcpSessions.Delete(sessionId)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	endpoint := strings.TrimSuffix(c.Request.URL.Path, "/sse") + "/message?sessionId=" + sessionId
+	c.SSEvent("endpoint", endpoint)
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-session.ctx.Done():
+			return
+		case resp := <-session.responses:
+			jsonResp, err := json.Marshal(resp)
+			if err != nil {
+				logger.Errorf(session.ctx, "Failed to marshal response: %v", err)
+				continue
+			}
+			c.SSEvent("message", string(jsonResp))
+			c.Writer.Flush()
+		}
+	}
+}
+
+// MCPMessageForHTTP @Summary MCP消息提交(SSE)
+// @Description 向 SSE 会话提交 JSON-RPC 消息, 结果通过对应的 SSE 连接推送
+// @Tags MCP
+// @Accept json
+// @Param sessionId query string true "会话ID"
+// @Param Authorization header string true "Authorization API-KEY"
+// @Router /mcp/message [post]
+func MCPMessageForHTTP(c *gin.Context) {
+	value, ok := mcpSessions.Load(c.Query("sessionId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	session := value.(*mcpSSESession)
+
+	var req model.MCPRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, mcpErrorResponse(nil, mcpParseError, "Parse error"))
+		return
+	}
+	c.Status(http.StatusAccepted)
+
+	// 结果随 SSE 连接推送, 生命周期跟随会话而非本次请求
+	reqCtx := context.WithValue(session.ctx, helper.RequestIdKey, c.GetString(helper.RequestIdKey))
+	go func() {
+		resp := session.handle(reqCtx, req)
+		if resp == nil {
+			return
+		}
+		select {
+		case session.responses <- *resp:
+		case <-session.ctx.Done():
+		}
+	}()
+}
+
+// handle 处理单条 JSON-RPC 消息, 通知返回 nil
+func (s *mcpSession) handle(ctx context.Context, req model.MCPRequest) *model.MCPResponse {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if req.IsNotification() {
+			return nil
+		}
+		return mcpErrorResponse(req.ID, mcpInvalidRequest, "Invalid Request")
+	}
+
+	switch req.Method {
+	case "notifications/initialized":
+		return nil
+	case "notifications/cancelled":
+		var params model.MCPCancelledParams
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			s.cancel(params.RequestID)
+		}
+		return nil
+	case "initialize":
+		var params model.MCPInitializeParams
+		_ = json.Unmarshal(req.Params, &params)
+		version := mcpProtocolVersion
+		for _, v := range mcpSupportedVersions {
+			if v == params.ProtocolVersion {
+				version = v
+			}
+		}
+		return mcpResultResponse(req.ID, model.MCPInitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    map[string]interface{}{"tools": map[string]interface{}{}},
+			ServerInfo:      model.MCPServerInfo{Name: mcpServerName, Version: common.Version},
+		})
+	case "ping":
+		return mcpResultResponse(req.ID, map[string]interface{}{})
+	case "tools/list":
+		return mcpResultResponse(req.ID, model.MCPToolListResult{Tools: mcpTools()})
+	case "tools/call":
+		var params model.MCPToolCallParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return mcpErrorResponse(req.ID, mcpInvalidParams, "Invalid params")
+		}
+		callCtx, cancel := context.WithCancel(ctx)
+		s.track(req.ID, cancel)
+		defer s.untrack(req.ID)
+		return s.callTool(callCtx, req.ID, params)
+	}
+
+	if req.IsNotification() {
+		return nil
+	}
+	return mcpErrorResponse(req.ID, mcpMethodNotFound, fmt.Sprintf("Method not found: %s", req.Method))
+}
+
+func (s *mcpSession) callTool(ctx context.Context, id json.RawMessage, params model.MCPToolCallParams) *model.MCPResponse {
+	switch params.Name {
+	case "list_models":
+		modelList := common.GetSGModelList()
+		sort.Strings(modelList)
+		return mcpResultResponse(id, mcpToolText(strings.Join(modelList, "\n"), false))
+	case "ask_model":
+		var args model.MCPAskModelArguments
+		if err := json.Unmarshal(params.Arguments, &args); err != nil || args.Model == "" || args.Prompt == "" {
+			return mcpErrorResponse(id, mcpInvalidParams, "Arguments model and prompt are required")
+		}
+		content, err := mcpAskModel(ctx, args)
+		if err != nil {
+			return mcpResultResponse(id, mcpToolText(err.Error(), true))
+		}
+		return mcpResultResponse(id, mcpToolText(content, false))
+	}
+	return mcpErrorResponse(id, mcpInvalidParams, fmt.Sprintf("Unknown tool: %s", params.Name))
+}
+
+// mcpAskModel 以单轮对话请求上游, 与 HTTP 接口共用 cookie 池及重试逻辑
+func mcpAskModel(ctx context.Context, args model.MCPAskModelArguments) (string, error) {
+	modelInfo, b := common.GetSGModelInfo(args.Model)
+	if !b {
+		return "", fmt.Errorf("Model %s not supported", args.Model)
+	}
+	if args.MaxTokens > modelInfo.MaxTokens {
+		return "", fmt.Errorf("Max tokens %d exceeds limit %d", args.MaxTokens, modelInfo.MaxTokens)
+	}
+
+	openAIReq := model.OpenAIChatCompletionRequest{
+		Model:     args.Model,
+		MaxTokens: args.MaxTokens,
+	}
+	if args.System != "" {
+		openAIReq.Messages = append(openAIReq.Messages, model.OpenAIChatMessage{Role: "system", Content: args.System})
+	}
+	openAIReq.Messages = append(openAIReq.Messages, model.OpenAIChatMessage{Role: "user", Content: args.Prompt})
+	if err := checkContextWindow(&openAIReq, modelInfo); err != nil {
+		return "", err
+	}
+
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	result, err := doUpstreamChat(ctx, client, &openAIReq, nil)
+	if err != nil {
+		return "", err
+	}
+	return result.Content, nil
+}
+
+func mcpTools() []model.MCPTool {
+	return []model.MCPTool{
+		{
+			Name:        "ask_model",
+			Description: "Send a single-turn prompt to a Sourcegraph-hosted model and return its answer. Use list_models for available model names.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"model":      map[string]interface{}{"type": "string", "description": "Model name, e.g. claude-sonnet-4-latest"},
+					"prompt":     map[string]interface{}{"type": "string", "description": "User prompt"},
+					"system":     map[string]interface{}{"type": "string", "description": "Optional system prompt"},
+					"max_tokens": map[string]interface{}{"type": "integer", "description": "Optional maximum tokens to generate"},
+				},
+				"required": []string{"model", "prompt"},
+			},
+		},
+		{
+			Name:        "list_models",
+			Description: "List the model names that ask_model accepts, one per line.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+}
+
+func (s *mcpSession) track(id json.RawMessage, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight[string(id)] = cancel
+}
+
+func (s *mcpSession) untrack(id json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.inflight[string(id)]; ok {
+		cancel()
+		delete(s.inflight, string(id))
+	}
+}
+
+// cancel 取消进行中的请求, 中断对应的上游连接
+func (s *mcpSession) cancel(id json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.inflight[string(id)]; ok {
+		cancel()
+	}
+}
+
+func mcpToolText(text string, isError bool) model.MCPToolCallResult {
+	return model.MCPToolCallResult{
+		Content: []model.MCPContent{{Type: "text", Text: text}},
+		IsError: isError,
+	}
+}
+
+func mcpResultResponse(id json.RawMessage, result interface{}) *model.MCPResponse {
+	return &model.MCPResponse{JSONRPC: "2.0", ID: id, Result: result}
+}
+
+func mcpErrorResponse(id json.RawMessage, code int, message string) *model.MCPResponse {
+	return &model.MCPResponse{JSONRPC: "2.0", ID: id, Error: &model.MCPError{Code: code, Message: message}}
+}
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sourcegraph2api/common"
+	"sourcegraph2api/common/config"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// imageMaxBytes 远程图片大小上限
+	imageMaxBytes       = 20 * 1024 * 1024
+	imageFetchTimeout   = 30 * time.Second
+	imageMaxRedirects   = 5
+	imageDataURLPrefix  = "data:image/"
+	imageDataURLFormat  = "data:%s;base64,%s"
+	imageUnsupportedMsg = "Model %s does not support image input"
+)
+
+// sharedAddressSpace 运营商级 NAT 地址段(100.64.0.0/10)
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// messageContent 将 content (字符串或内容块数组) 转换为上游文本及内容块
+// 仅在包含图片时返回内容块, 远程图片下载后原地替换为 data URL, 重试时无需重复下载
+func messageContent(ctx context.Context, modelName string, content interface{}) (string, []map[string]interface{}, error) {
+	items, ok := content.([]interface{})
+	if !ok {
+		text, _ := content.(string)
+		return text, nil, nil
+	}
+
+	var texts []string
+	var parts []map[string]interface{}
+	hasImage := false
+	for _, it := range items {
+		part, ok := it.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch part["type"] {
+		case "text", "input_text":
+			text, _ := part["text"].(string)
+			texts = append(texts, text)
+			parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+		case "image_url":
+			if !common.IsVisionModel(modelName) {
+				return "", nil, &upstreamError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf(imageUnsupportedMsg, modelName)}
+			}
+			dataURL, err := resolveImageURL(ctx, part)
+			if err != nil {
+				return "", nil, &upstreamError{StatusCode: http.StatusBadRequest, Message: err.Error()}
+			}
+			hasImage = true
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": dataURL},
+			})
+		}
+	}
+	if !hasImage {
+		parts = nil
+	}
+	return strings.Join(texts, "\n"), parts, nil
+}
+
+// messageText 获取 content (字符串或内容块数组) 中的文本, 不处理图片
+func messageText(content interface{}) string {
+	items, ok := content.([]interface{})
+	if !ok {
+		text, _ := content.(string)
+		return text
+	}
+	var texts []string
+	for _, it := range items {
+		if part, ok := it.(map[string]interface{}); ok && (part["type"] == "text" || part["type"] == "input_text") {
+			text, _ := part["text"].(string)
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// validateMessageContent 校验 content 为字符串或内容块数组, 内容块字段类型正确
+func validateMessageContent(messages []model.OpenAIChatMessage) error {
+	for i, msg := range messages {
+		switch content := msg.Content.(type) {
+		case nil, string:
+			continue
+		case []interface{}:
+			for j, it := range content {
+				if err := validateContentPart(it); err != nil {
+					return fmt.Errorf("Invalid messages[%d].content[%d]: %v", i, j, err)
+				}
+			}
+		default:
+			return fmt.Errorf("Invalid messages[%d].content: expected a string or an array of content parts", i)
+		}
+	}
+	return nil
+}
+
+// validateContentPart 校验单个内容块
+func validateContentPart(it interface{}) error {
+	part, ok := it.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected an object")
+	}
+	partType, ok := part["type"].(string)
+	if !ok {
+		return fmt.Errorf("type must be a string")
+	}
+	switch partType {
+	case "text", "input_text":
+		if _, ok := part["text"].(string); !ok {
+			return fmt.Errorf("text must be a string")
+		}
+	case "image_url":
+		switch v := part["image_url"].(type) {
+		case string:
+			if v == "" {
+				return fmt.Errorf("image_url must not be empty")
+			}
+		case map[string]interface{}:
+			if url, ok := v["url"].(string); !ok || url == "" {
+				return fmt.Errorf("image_url.url must be a non-empty string")
+			}
+			if detail, ok := v["detail"]; ok && detail != nil {
+				if _, ok := detail.(string); !ok {
+					return fmt.Errorf("image_url.detail must be a string")
+				}
+			}
+		default:
+			return fmt.Errorf("image_url must be a string or an object")
+		}
+	}
+	return nil
+}
+
+// hasImageContent 消息中是否包含图片
+func hasImageContent(messages []model.OpenAIChatMessage) bool {
+	for _, msg := range messages {
+		items, ok := msg.Content.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, it := range items {
+			if part, ok := it.(map[string]interface{}); ok && part["type"] == "image_url" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveImageURL 校验 image_url 内容块, 远程图片转换为 data URL
+func resolveImageURL(ctx context.Context, part map[string]interface{}) (string, error) {
+	var imageURL string
+	switch v := part["image_url"].(type) {
+	case string:
+		imageURL = v
+	case map[string]interface{}:
+		imageURL, _ = v["url"].(string)
+	}
+
+	if strings.HasPrefix(imageURL, imageDataURLPrefix) {
+		if !common.IsImageBase64(imageURL) {
+			return "", fmt.Errorf("Invalid image data URL")
+		}
+		return imageURL, nil
+	}
+
+	u, err := url.Parse(imageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("Invalid image url, expected a data URL or an http(s) URL")
+	}
+	dataURL, err := fetchImageDataURL(ctx, imageURL)
+	if err != nil {
+		logger.Warnf(ctx, "fetch image err: %v", err)
+		return "", fmt.Errorf("Failed to fetch image %s: %v", imageURL, err)
+	}
+	part["image_url"] = map[string]interface{}{"url": dataURL}
+	return dataURL, nil
+}
+
+// fetchImageDataURL 下载远程图片并编码为 data URL, 仅允许访问公网地址(含重定向)
+func fetchImageDataURL(ctx context.Context, imageURL string) (string, error) {
+	// 直连时在建立连接前校验实际连接的 IP, 防止 DNS 重绑定
+	dialer := &net.Dialer{Timeout: imageFetchTimeout, Control: func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+			return fmt.Errorf("image host %s is not a public address", host)
+		}
+		return nil
+	}}
+	transport := &http.Transport{DialContext: dialer.DialContext}
+	if config.ProxyUrl != "" {
+		if proxyURL, err := url.Parse(config.ProxyUrl); err == nil {
+			// 经代理访问时连接的是代理地址, 改为校验目标主机解析结果
+			transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+		}
+	}
+	client := &http.Client{
+		Timeout:   imageFetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= imageMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", imageMaxRedirects)
+			}
+			return checkImageHost(req.Context(), req.URL.Hostname())
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := checkImageHost(ctx, req.URL.Hostname()); err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, imageMaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > imageMaxBytes {
+		return "", fmt.Errorf("image exceeds %d bytes", imageMaxBytes)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("unsupported content type %s", mimeType)
+	}
+	return fmt.Sprintf(imageDataURLFormat, mimeType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// checkImageHost 解析图片主机, 任一地址不是公网地址时拒绝
+func checkImageHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("image host %s is not a public address", host)
+		}
+	}
+	return nil
+}
+
+// isPublicIP 是否为公网地址, 回环、私有、链路本地(含 169.254.169.254)、CGNAT 等地址返回 false
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	return !sharedAddressSpace.Contains(ip)
+}
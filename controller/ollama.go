@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"sourcegraph2api/common"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"time"
+)
+
+const (
+	ollamaVersion = "0.6.8"
+)
+
+// ChatForOllama @Summary Ollama对话接口
+// @Description Ollama /api/chat 对话接口, 流式响应为 NDJSON
+// @Tags Ollama
+// @Accept json
+// @Produce json
+// @Param req body model.OllamaChatRequest true "Ollama对话请求"
+// @Router /api/chat [post]
+func ChatForOllama(c *gin.Context) {
+	var ollamaReq model.OllamaChatRequest
+	if err := c.BindJSON(&ollamaReq); err != nil {
+		logger.Errorf(c.Request.Context(), err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		return
+	}
+	openAIReq, err := ollamaReq.ToOpenAIRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	handleOllamaRequest(c, openAIReq, ollamaReq.Model, true)
+}
+
+// GenerateForOllama @Summary Ollama生成接口
+// @Description Ollama /api/generate 生成接口, 流式响应为 NDJSON
+// @Tags Ollama
+// @Accept json
+// @Produce json
+// @Param req body model.OllamaGenerateRequest true "Ollama生成请求"
+// @Router /api/generate [post]
+func GenerateForOllama(c *gin.Context) {
+	var ollamaReq model.OllamaGenerateRequest
+	if err := c.BindJSON(&ollamaReq); err != nil {
+		logger.Errorf(c.Request.Context(), err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		return
+	}
+	openAIReq, err := ollamaReq.ToOpenAIRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	handleOllamaRequest(c, openAIReq, ollamaReq.Model, false)
+}
+
+// TagsForOllama @Summary Ollama模型列表
+// @Description Ollama /api/tags 模型列表
+// @Tags Ollama
+// @Produce json
+// @Router /api/tags [get]
+func TagsForOllama(c *gin.Context) {
+	modelList := common.GetSGModelList()
+	sort.Strings(modelList)
+
+	modifiedAt := time.Unix(common.StartTime, 0).Format(time.RFC3339)
+	models := make([]model.OllamaModel, 0, len(modelList))
+	for _, modelName := range modelList {
+		modelInfo, _ := common.GetSGModelInfo(modelName)
+		models = append(models, model.OllamaModel{
+			Name:       model.OllamaTaggedName(modelName),
+			Model:      model.OllamaTaggedName(modelName),
+			ModifiedAt: modifiedAt,
+			Digest:     common.StringToSHA256(modelInfo.ModelRef),
+			Details:    ollamaModelDetails(modelInfo),
+		})
+	}
+	c.JSON(http.StatusOK, model.OllamaTagsResponse{Models: models})
+}
+
+// ShowForOllama @Summary Ollama模型详情
+// @Description Ollama /api/show 模型详情
+// @Tags Ollama
+// @Accept json
+// @Produce json
+// @Param req body model.OllamaShowRequest true "Ollama模型详情请求"
+// @Router /api/show [post]
+func ShowForOllama(c *gin.Context) {
+	var showReq model.OllamaShowRequest
+	if err := c.BindJSON(&showReq); err != nil {
+		logger.Errorf(c.Request.Context(), err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		return
+	}
+	modelName := showReq.Model
+	if modelName == "" {
+		modelName = showReq.Name
+	}
+	modelInfo, b := common.GetSGModelInfo(model.OllamaModelName(modelName))
+	if !b {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model '%s' not found", modelName)})
+		return
+	}
+
+	details := ollamaModelDetails(modelInfo)
+	c.JSON(http.StatusOK, model.OllamaShowResponse{
+		Parameters: fmt.Sprintf("num_ctx %d", modelInfo.ContextWindow),
+		Details:    details,
+		ModelInfo: map[string]interface{}{
+			"general.architecture":             details.Family,
+			"general.basename":                 modelInfo.Model,
+			details.Family + ".context_length": modelInfo.ContextWindow,
+			"sourcegraph.model_ref":            modelInfo.ModelRef,
+		},
+	})
+}
+
+// VersionForOllama @Summary Ollama版本
+// @Description Ollama /api/version, 部分客户端用于探测服务
+// @Tags Ollama
+// @Produce json
+// @Router /api/version [get]
+func VersionForOllama(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"version": ollamaVersion})
+}
+
+// handleOllamaRequest requestModel 为客户端请求的模型名(可带 :latest 标签), 响应中原样返回
+func handleOllamaRequest(c *gin.Context, openAIReq model.OpenAIChatCompletionRequest, requestModel string, isChat bool) {
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	modelInfo, b := common.GetSGModelInfo(openAIReq.Model)
+	if !b {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("model '%s' not found", requestModel)})
+		return
+	}
+	if openAIReq.MaxTokens > modelInfo.MaxTokens {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Max tokens %d exceeds limit %d", openAIReq.MaxTokens, modelInfo.MaxTokens)})
+		return
+	}
+	if !common.IsVisionModel(openAIReq.Model) && hasImageContent(openAIReq.Messages) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf(imageUnsupportedMsg, requestModel)})
+		return
+	}
+	openAIReq.RemoveEmptyContentMessages()
+	if err := checkContextWindow(&openAIReq, modelInfo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startTime := time.Now()
+	createChunk := func(text string, done bool) model.OllamaChatResponse {
+		chunk := model.OllamaChatResponse{
+			Model:     requestModel,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+			Done:      done,
+		}
+		if isChat {
+			chunk.Message = &model.OllamaChatMessage{Role: "assistant", Content: text}
+		} else {
+			chunk.Response = &text
+		}
+		return chunk
+	}
+
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+
+	result, err := doUpstreamChat(c.Request.Context(), client, &openAIReq, func(delta string) bool {
+		if openAIReq.Stream {
+			start()
+			sendNDJSON(c, createChunk(delta, false))
+		}
+		return true
+	})
+	if err != nil {
+		if !started {
+			c.JSON(upstreamErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		sendNDJSON(c, gin.H{"error": err.Error()})
+		return
+	}
+
+	doneReason := result.finishReason()
+	final := createChunk("", true)
+	if !openAIReq.Stream {
+		final = createChunk(result.Content, true)
+	}
+	final.DoneReason = doneReason
+	final.TotalDuration = time.Since(startTime).Nanoseconds()
+	final.PromptEvalCount = model.CountTokenMessages(openAIReq.Messages, openAIReq.Model)
+	final.EvalCount = model.CountTokenText(result.Content, openAIReq.Model)
+
+	if !openAIReq.Stream {
+		c.JSON(http.StatusOK, final)
+		return
+	}
+	start()
+	sendNDJSON(c, final)
+}
+
+// ollamaModelDetails 生成模型详情, family 为模型提供方
+func ollamaModelDetails(modelInfo common.SGModelInfo) model.OllamaModelDetails {
+	family := modelInfo.Provider()
+	return model.OllamaModelDetails{
+		Format:   "sourcegraph",
+		Family:   family,
+		Families: []string{family},
+	}
+}
+
+// sendNDJSON 发送一行 NDJSON
+func sendNDJSON(c *gin.Context, data interface{}) {
+	jsonResp, err := json.Marshal(data)
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "Failed to marshal response: %v", err)
+		return
+	}
+	_, _ = c.Writer.Write(append(jsonResp, '\n'))
+	c.Writer.Flush()
+}
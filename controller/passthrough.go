@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/sourcegraphapi"
+)
+
+// CompletionsStreamForCody @Summary Cody透传接口
+// @Description 以 Sourcegraph 实例的形式接收 Cody 客户端请求, 使用 cookie 池透传至 sourcegraph.com
+// @Tags Sourcegraph
+// @Accept json
+// @Produce text/event-stream
+// @Param api-version query string false "API 版本"
+// @Param client-name query string false "客户端名称"
+// @Param Authorization header string true "token API-KEY"
+// @Router /.api/completions/stream [post]
+func CompletionsStreamForCody(c *gin.Context) {
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	ctx := c.Request.Context()
+	jsonData, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logger.Errorf(ctx, err.Error())
+		c.String(http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	var body struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(jsonData, &body); err != nil {
+		c.String(http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rawQuery := c.Request.URL.RawQuery
+	request := func(ctx context.Context, cookie string) (<-chan cycletls.SSEResponse, error) {
+		return sourcegraphapi.MakePassthroughStreamRequest(ctx, client, rawQuery, jsonData, cookie)
+	}
+
+	started := false
+	err = doUpstreamStream(ctx, body.Model, request, func(data string) bool {
+		if !started {
+			started = true
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Status(http.StatusOK)
+		}
+		// 上游只返回去除 "data: " 前缀的数据行, 按内容还原事件名
+		line := fmt.Sprintf("event: %s\ndata: %s\n\n", codyEventName(data), data)
+		if _, err := io.WriteString(c.Writer, line); err != nil {
+			logger.Warnf(ctx, "write stream err: %v", err)
+			return false
+		}
+		c.Writer.Flush()
+		return true
+	})
+	if err != nil && !started {
+		c.String(upstreamErrorStatus(err), err.Error())
+	}
+}
+
+// codyEventName 根据数据内容判断 Sourcegraph SSE 事件名: error、done 或 completion
+func codyEventName(data string) string {
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return "completion"
+	}
+	if _, ok := event["error"]; ok {
+		return "error"
+	}
+	if len(event) == 0 {
+		return "done"
+	}
+	return "completion"
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"sourcegraph2api/common/config"
+	"sourcegraph2api/model"
+)
+
+const (
+	thinkStartTag = "<think>\n"
+	thinkEndTag   = "\n</think>\n\n"
+)
+
+// reasoningHidden 是否隐藏思考过程(REASONING_HIDE=1)
+func reasoningHidden() bool {
+	return config.ReasoningHide == 1
+}
+
+// reasoningAsThinkTag 是否以 <think> 标签将思考过程拼接在正文前(REASONING_FORMAT=think)
+func reasoningAsThinkTag() bool {
+	return config.ReasoningFormat == "think"
+}
+
+// applyReasoning 按配置将思考过程写入非流式响应消息
+func applyReasoning(message *model.OpenAIMessage, reasoning string) {
+	if reasoning == "" || reasoningHidden() {
+		return
+	}
+	if reasoningAsThinkTag() {
+		message.Content = thinkStartTag + reasoning + thinkEndTag + message.Content
+		return
+	}
+	message.ReasoningContent = reasoning
+}
+
+// reasoningTokens 思考过程的 token 数, 计入 completion_tokens 并单独返回 reasoning_tokens
+func reasoningTokens(reasoning, modelName string) int {
+	if reasoning == "" {
+		return 0
+	}
+	return model.CountTokenText(reasoning, modelName)
+}
+
+// thinkTagStream 流式输出时为思考过程补全 <think> 标签
+type thinkTagStream struct {
+	open bool
+}
+
+// reasoning 返回思考增量, 首个增量前添加开始标签
+func (s *thinkTagStream) reasoning(text string) string {
+	if !s.open {
+		s.open = true
+		return thinkStartTag + text
+	}
+	return text
+}
+
+// content 返回正文增量, 思考过程未结束时先添加结束标签
+func (s *thinkTagStream) content(text string) string {
+	if s.open {
+		s.open = false
+		return thinkEndTag + text
+	}
+	return text
+}
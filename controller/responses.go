@@ -0,0 +1,453 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sourcegraph2api/common"
+	"sourcegraph2api/common/config"
+	"sourcegraph2api/common/helper"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"sync"
+	"time"
+)
+
+const (
+	responseObjectIDFormat = "resp_%s"
+	responseItemIDFormat   = "msg_%s"
+)
+
+// storedResponse 保存的 Responses API 结果
+type storedResponse struct {
+	mu       sync.Mutex
+	response model.OpenAIResponse
+	// messages 不含 instructions 的完整上下文(含本次输出), 供 previous_response_id 续接
+	messages       []model.OpenAIChatMessage
+	itemId         string
+	cancel         context.CancelFunc
+	expirationTime time.Time
+}
+
+var (
+	responseStore sync.Map // 使用 sync.Map 管理 Responses 结果
+)
+
+func saveResponse(stored *storedResponse) {
+	stored.expirationTime = time.Now().Add(time.Duration(config.ResponseStoreDuration) * time.Second)
+	responseStore.Store(stored.response.ID, stored)
+
+	// 清理过期结果
+	responseStore.Range(func(key, value interface{}) bool {
+		if s, ok := value.(*storedResponse); ok && s.expirationTime.Before(time.Now()) {
+			responseStore.Delete(key)
+		}
+		return true
+	})
+}
+
+func loadResponse(id string) (*storedResponse, bool) {
+	value, ok := responseStore.Load(id)
+	if !ok {
+		return nil, false
+	}
+	stored := value.(*storedResponse)
+	if stored.expirationTime.Before(time.Now()) {
+		responseStore.Delete(id)
+		return nil, false
+	}
+	return stored, true
+}
+
+// snapshot 获取当前结果的副本
+func (s *storedResponse) snapshot() model.OpenAIResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.response
+}
+
+// ResponsesForOpenAI @Summary OpenAI Responses接口
+// @Description OpenAI Responses 接口, 支持 previous_response_id 续接及 background 后台执行
+// @Tags OpenAI
+// @Accept json
+// @Produce json
+// @Param req body model.OpenAIResponsesRequest true "OpenAI Responses请求"
+// @Param Authorization header string true "Authorization API-KEY"
+// @Router /v1/responses [post]
+func ResponsesForOpenAI(c *gin.Context) {
+	var responsesReq model.OpenAIResponsesRequest
+	if err := c.BindJSON(&responsesReq); err != nil {
+		logger.Errorf(c.Request.Context(), err.Error())
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_request", "Invalid request parameters")
+		return
+	}
+	if responsesReq.Reasoning != nil {
+		resolved, err := common.ResolveReasoningModel(responsesReq.Model, responsesReq.Reasoning.Effort)
+		if err != nil {
+			sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_reasoning_effort", err.Error())
+			return
+		}
+		responsesReq.Model = resolved
+	}
+	modelInfo, b := common.GetSGModelInfo(responsesReq.Model)
+	if !b {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_model", fmt.Sprintf("Model %s not supported", responsesReq.Model))
+		return
+	}
+	if responsesReq.MaxOutputTokens > modelInfo.MaxTokens {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_max_tokens", fmt.Sprintf("Max tokens %d exceeds limit %d", responsesReq.MaxOutputTokens, modelInfo.MaxTokens))
+		return
+	}
+	if responsesReq.Background && responsesReq.Stream {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_request", "Streaming is not supported for background responses, poll GET /v1/responses/{id} instead")
+		return
+	}
+
+	var history []model.OpenAIChatMessage
+	if responsesReq.PreviousResponseID != "" {
+		previous, ok := loadResponse(responsesReq.PreviousResponseID)
+		if !ok {
+			sendOpenAIError(c, http.StatusNotFound, "invalid_request_error", "previous_response_not_found", fmt.Sprintf("Previous response with id '%s' not found.", responsesReq.PreviousResponseID))
+			return
+		}
+		previous.mu.Lock()
+		history = append(history, previous.messages...)
+		previous.mu.Unlock()
+	}
+
+	input, err := responsesReq.InputMessages()
+	if err != nil {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return
+	}
+	messages := append(history, input...)
+	openAIReq := responsesReq.ToOpenAIRequest(messages)
+	if !common.IsVisionModel(openAIReq.Model) && hasImageContent(openAIReq.Messages) {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_request", fmt.Sprintf(imageUnsupportedMsg, openAIReq.Model))
+		return
+	}
+	openAIReq.RemoveEmptyContentMessages()
+	if err := checkContextWindow(&openAIReq, modelInfo); err != nil {
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded", err.Error())
+		return
+	}
+
+	stored := &storedResponse{
+		response: createOpenAIResponse(responsesReq),
+		messages: messages,
+		itemId:   fmt.Sprintf(responseItemIDFormat, common.GetUUID()),
+	}
+
+	if responsesReq.Background {
+		handleBackgroundResponseRequest(c, stored, openAIReq)
+		return
+	}
+
+	client := cycletls.Init()
+	defer safeClose(client)
+
+	if responsesReq.Stream {
+		handleStreamResponseRequest(c, client, stored, openAIReq)
+	} else {
+		handleNonStreamResponseRequest(c, client, stored, openAIReq)
+	}
+
+	// 请求未完成(如上游直接报错)时不保存
+	if (responsesReq.Store == nil || *responsesReq.Store) && stored.snapshot().Status != "in_progress" {
+		saveResponse(stored)
+	}
+}
+
+func handleNonStreamResponseRequest(c *gin.Context, client cycletls.CycleTLS, stored *storedResponse, openAIReq model.OpenAIChatCompletionRequest) {
+	result, err := doUpstreamChat(c.Request.Context(), client, &openAIReq, nil)
+	if err != nil {
+		sendOpenAIError(c, upstreamErrorStatus(err), "api_error", "upstream_error", err.Error())
+		return
+	}
+	completeResponse(stored, openAIReq, result, nil, false)
+	c.JSON(http.StatusOK, stored.snapshot())
+}
+
+func handleStreamResponseRequest(c *gin.Context, client cycletls.CycleTLS, stored *storedResponse, openAIReq model.OpenAIChatCompletionRequest) {
+	sequenceNumber := 0
+	send := func(event model.OpenAIResponseStreamEvent) {
+		event.SequenceNumber = sequenceNumber
+		sequenceNumber++
+		jsonResp, err := json.Marshal(event)
+		if err != nil {
+			logger.Errorf(c.Request.Context(), "Failed to marshal response: %v", err)
+			return
+		}
+		c.SSEvent(event.Type, " "+string(jsonResp))
+		c.Writer.Flush()
+	}
+
+	outputIndex, contentIndex := 0, 0
+	itemId := stored.itemId
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		response := stored.snapshot()
+		send(model.OpenAIResponseStreamEvent{Type: "response.created", Response: &response})
+		send(model.OpenAIResponseStreamEvent{Type: "response.in_progress", Response: &response})
+		send(model.OpenAIResponseStreamEvent{
+			Type:        "response.output_item.added",
+			OutputIndex: &outputIndex,
+			Item:        &model.OpenAIResponseOutputItem{Type: "message", ID: itemId, Status: "in_progress", Role: "assistant", Content: []model.OpenAIResponseContent{}},
+		})
+		send(model.OpenAIResponseStreamEvent{
+			Type:         "response.content_part.added",
+			ItemID:       itemId,
+			OutputIndex:  &outputIndex,
+			ContentIndex: &contentIndex,
+			Part:         &model.OpenAIResponseContent{Type: "output_text", Text: "", Annotations: []interface{}{}},
+		})
+	}
+
+	result, err := doUpstreamChat(c.Request.Context(), client, &openAIReq, func(delta string) bool {
+		start()
+		send(model.OpenAIResponseStreamEvent{
+			Type:         "response.output_text.delta",
+			ItemID:       itemId,
+			OutputIndex:  &outputIndex,
+			ContentIndex: &contentIndex,
+			Delta:        &delta,
+		})
+		return true
+	})
+	if err != nil {
+		if !started {
+			sendOpenAIError(c, upstreamErrorStatus(err), "api_error", "upstream_error", err.Error())
+			return
+		}
+		completeResponse(stored, openAIReq, nil, err, false)
+		response := stored.snapshot()
+		send(model.OpenAIResponseStreamEvent{Type: "response.failed", Response: &response})
+		return
+	}
+	start()
+
+	completeResponse(stored, openAIReq, result, nil, false)
+	response := stored.snapshot()
+
+	item := response.Output[0]
+	part := item.Content[0]
+	send(model.OpenAIResponseStreamEvent{
+		Type:         "response.output_text.done",
+		ItemID:       itemId,
+		OutputIndex:  &outputIndex,
+		ContentIndex: &contentIndex,
+		Text:         &part.Text,
+	})
+	send(model.OpenAIResponseStreamEvent{
+		Type:         "response.content_part.done",
+		ItemID:       itemId,
+		OutputIndex:  &outputIndex,
+		ContentIndex: &contentIndex,
+		Part:         &part,
+	})
+	send(model.OpenAIResponseStreamEvent{
+		Type:        "response.output_item.done",
+		OutputIndex: &outputIndex,
+		Item:        &item,
+	})
+	eventType := "response.completed"
+	if response.Status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	send(model.OpenAIResponseStreamEvent{Type: eventType, Response: &response})
+}
+
+// handleBackgroundResponseRequest 后台执行请求, 立即返回 queued 状态, 客户端通过 GET /v1/responses/{id} 轮询结果
+func handleBackgroundResponseRequest(c *gin.Context, stored *storedResponse, openAIReq model.OpenAIChatCompletionRequest) {
+	// 后台任务不随客户端连接结束而取消
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), helper.RequestIdKey, c.GetString(helper.RequestIdKey)))
+	stored.response.Status = "queued"
+	stored.cancel = cancel
+	saveResponse(stored)
+
+	go func() {
+		defer cancel()
+		client := cycletls.Init()
+		defer safeClose(client)
+
+		stored.mu.Lock()
+		if stored.response.Status == "queued" {
+			stored.response.Status = "in_progress"
+		}
+		stored.mu.Unlock()
+
+		result, err := doUpstreamChat(ctx, client, &openAIReq, func(delta string) bool {
+			return ctx.Err() == nil
+		})
+		if err != nil {
+			logger.Errorf(ctx, "Background response %s failed: %v", stored.response.ID, err)
+		}
+		completeResponse(stored, openAIReq, result, err, ctx.Err() != nil)
+	}()
+
+	c.JSON(http.StatusOK, stored.snapshot())
+}
+
+// GetResponseForOpenAI @Summary 获取Responses结果
+// @Description 获取已保存或后台执行中的 Responses 结果
+// @Tags OpenAI
+// @Produce json
+// @Param id path string true "response id"
+// @Param Authorization header string true "Authorization API-KEY"
+// @Router /v1/responses/{id} [get]
+func GetResponseForOpenAI(c *gin.Context) {
+	stored, ok := loadResponse(c.Param("id"))
+	if !ok {
+		sendOpenAIError(c, http.StatusNotFound, "invalid_request_error", "not_found", fmt.Sprintf("Response with id '%s' not found.", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, stored.snapshot())
+}
+
+// CancelResponseForOpenAI @Summary 取消后台Responses请求
+// @Description 取消 background 模式下仍在执行的 Responses 请求
+// @Tags OpenAI
+// @Produce json
+// @Param id path string true "response id"
+// @Param Authorization header string true "Authorization API-KEY"
+// @Router /v1/responses/{id}/cancel [post]
+func CancelResponseForOpenAI(c *gin.Context) {
+	stored, ok := loadResponse(c.Param("id"))
+	if !ok {
+		sendOpenAIError(c, http.StatusNotFound, "invalid_request_error", "not_found", fmt.Sprintf("Response with id '%s' not found.", c.Param("id")))
+		return
+	}
+
+	stored.mu.Lock()
+	if !stored.response.Background {
+		stored.mu.Unlock()
+		sendOpenAIError(c, http.StatusBadRequest, "invalid_request_error", "invalid_request", "Only background responses can be cancelled.")
+		return
+	}
+	if stored.response.Status == "queued" || stored.response.Status == "in_progress" {
+		stored.response.Status = "cancelled"
+		if stored.cancel != nil {
+			stored.cancel()
+		}
+	}
+	stored.mu.Unlock()
+
+	c.JSON(http.StatusOK, stored.snapshot())
+}
+
+// DeleteResponseForOpenAI @Summary 删除Responses结果
+// @Description 删除已保存的 Responses 结果, 执行中的后台请求会被取消
+// @Tags OpenAI
+// @Produce json
+// @Param id path string true "response id"
+// @Param Authorization header string true "Authorization API-KEY"
+// @Router /v1/responses/{id} [delete]
+func DeleteResponseForOpenAI(c *gin.Context) {
+	id := c.Param("id")
+	stored, ok := loadResponse(id)
+	if !ok {
+		sendOpenAIError(c, http.StatusNotFound, "invalid_request_error", "not_found", fmt.Sprintf("Response with id '%s' not found.", id))
+		return
+	}
+	stored.mu.Lock()
+	if stored.cancel != nil {
+		stored.cancel()
+	}
+	stored.mu.Unlock()
+	responseStore.Delete(id)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      id,
+		"object":  "response.deleted",
+		"deleted": true,
+	})
+}
+
+// createOpenAIResponse 创建初始状态的 Responses 结果
+func createOpenAIResponse(responsesReq model.OpenAIResponsesRequest) model.OpenAIResponse {
+	response := model.OpenAIResponse{
+		ID:          fmt.Sprintf(responseObjectIDFormat, common.GetUUID()),
+		Object:      "response",
+		CreatedAt:   time.Now().Unix(),
+		Status:      "in_progress",
+		Background:  responsesReq.Background,
+		Model:       responsesReq.Model,
+		Output:      []model.OpenAIResponseOutputItem{},
+		Temperature: responsesReq.Temperature,
+	}
+	if responsesReq.Instructions != "" {
+		response.Instructions = &responsesReq.Instructions
+	}
+	if responsesReq.MaxOutputTokens > 0 {
+		response.MaxOutputTokens = &responsesReq.MaxOutputTokens
+	}
+	if responsesReq.PreviousResponseID != "" {
+		response.PreviousResponseID = &responsesReq.PreviousResponseID
+	}
+	return response
+}
+
+// completeResponse 根据上游结果更新 Responses 状态、输出及用量
+func completeResponse(stored *storedResponse, openAIReq model.OpenAIChatCompletionRequest, result *upstreamResult, err error, cancelled bool) {
+	stored.mu.Lock()
+	defer stored.mu.Unlock()
+
+	stored.cancel = nil
+	switch {
+	case cancelled || stored.response.Status == "cancelled":
+		stored.response.Status = "cancelled"
+		return
+	case err != nil:
+		stored.response.Status = "failed"
+		stored.response.Error = &model.OpenAIResponseError{Code: "server_error", Message: err.Error()}
+		return
+	}
+
+	stored.response.Status = "completed"
+	switch result.finishReason() {
+	case "length":
+		stored.response.Status = "incomplete"
+		stored.response.IncompleteDetails = &model.OpenAIIncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		stored.response.Status = "incomplete"
+		stored.response.IncompleteDetails = &model.OpenAIIncompleteDetails{Reason: "content_filter"}
+	}
+	stored.response.Output = []model.OpenAIResponseOutputItem{{
+		Type:    "message",
+		ID:      stored.itemId,
+		Status:  "completed",
+		Role:    "assistant",
+		Content: []model.OpenAIResponseContent{{Type: "output_text", Text: result.Content, Annotations: []interface{}{}}},
+	}}
+	inputTokens := model.CountTokenMessages(openAIReq.Messages, openAIReq.Model)
+	outputTokens := model.CountTokenText(result.Content, openAIReq.Model)
+	stored.response.Usage = &model.OpenAIResponseUsage{
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		TotalTokens:  inputTokens + outputTokens,
+	}
+	stored.messages = append(stored.messages, model.OpenAIChatMessage{
+		Role:    "assistant",
+		Content: result.Content,
+	})
+}
+
+func sendOpenAIError(c *gin.Context, status int, errorType, code, message string) {
+	c.JSON(status, model.OpenAIErrorResponse{
+		OpenAIError: model.OpenAIError{
+			Message: message,
+			Type:    errorType,
+			Code:    code,
+		},
+	})
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sourcegraph2api/common"
+	"sourcegraph2api/model"
+	"strings"
+)
+
+const (
+	speakerHuman     = "human"
+	speakerAssistant = "assistant"
+	speakerSystem    = "system"
+
+	// continuePrompt 对话以 assistant 发言结束时追加的 human 发言
+	continuePrompt = "Continue."
+	// splitPartFormat 超长消息拆分后各部分的前缀
+	splitPartFormat     = "[Part %d/%d of a long message. More parts follow, reply only with an acknowledgement until the last part.]\n"
+	splitLastPartFormat = "[Part %d/%d of a long message. This is the last part, the message is now complete.]\n"
+	// splitAckFormat 各部分之间的 assistant 占位回复
+	splitAckFormat = "Received part %d/%d."
+	// prefillPrompt 模型不支持预填充时, 要求模型从给定文本处续写
+	prefillPrompt = "Your reply begins with the exact text below. Output only the continuation that follows it, without repeating any of it:\n\n%s"
+)
+
+// upstreamMessage 发送给 Sourcegraph 的一轮发言
+type upstreamMessage struct {
+	Speaker string
+	Text    string
+	// Parts 包含图片时的内容块
+	Parts []map[string]interface{}
+}
+
+func (m upstreamMessage) toMap() map[string]interface{} {
+	message := map[string]interface{}{
+		"speaker": m.Speaker,
+		"text":    m.Text,
+	}
+	// 包含图片时以内容块形式发送, text 保留拼接后的文本
+	if len(m.Parts) > 0 {
+		message["content"] = m.Parts
+	}
+	return message
+}
+
+// normalizeMessages 将 OpenAI 消息转换为 Sourcegraph 发言
+// user、tool 转为 human, developer 视为 system, 开头的 system 消息与 prompts 合并为一轮 system 发言,
+// 模型不支持 system 或对话中间的 system 消息转为 human, 相邻的同一发言方合并为一轮
+// 对话以 assistant 消息结束时作为回复前缀(预填充), 模型不支持时改为 human 发言要求续写, 其余情况保证以 human 发言结束
+func normalizeMessages(ctx context.Context, req *model.OpenAIChatCompletionRequest, modelInfo common.SGModelInfo, prompts []string) ([]upstreamMessage, error) {
+	// 在副本上处理, 重试及并行生成时不修改原请求
+	normalized := *req
+	normalized.Messages = append([]model.OpenAIChatMessage(nil), req.Messages...)
+	normalized.SystemMessagesProcess(modelInfo.SupportsSystem())
+
+	var systemTexts []string
+	var messages []upstreamMessage
+	leading := true
+	for _, msg := range normalized.Messages {
+		text, parts, err := messageContent(ctx, req.Model, msg.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		speaker := speakerHuman
+		switch msg.Role {
+		case "system":
+			if leading {
+				systemTexts = append(systemTexts, text)
+				continue
+			}
+		case "assistant":
+			speaker = speakerAssistant
+			if len(msg.ToolCalls) > 0 {
+				msg.Content = text
+				text = toolMessageText(msg)
+			}
+		case "tool", "function":
+			// 工具结果以 human 发言返回给模型
+			msg.Role = "tool"
+			msg.Content = text
+			text = toolMessageText(msg)
+		}
+		leading = false
+		messages = appendTurn(messages, upstreamMessage{Speaker: speaker, Text: text, Parts: parts})
+	}
+
+	systemTexts = append(systemTexts, prompts...)
+	if systemText := strings.Join(systemTexts, "\n\n"); systemText != "" {
+		if modelInfo.SupportsSystem() {
+			messages = append([]upstreamMessage{{Speaker: speakerSystem, Text: systemText}}, messages...)
+		} else {
+			turns := []upstreamMessage{{Speaker: speakerHuman, Text: systemText}}
+			for _, message := range messages {
+				turns = appendTurn(turns, message)
+			}
+			messages = turns
+		}
+	}
+
+	if len(messages) == 0 || messages[len(messages)-1].Speaker == speakerHuman {
+		return messages, nil
+	}
+	if _, ok := assistantPrefill(req); !ok {
+		return append(messages, upstreamMessage{Speaker: speakerHuman, Text: continuePrompt}), nil
+	}
+	last := &messages[len(messages)-1]
+	if modelInfo.SupportsPrefill() {
+		// 预填充内容不能以空白结尾
+		last.Text = strings.TrimRight(last.Text, " \t\r\n")
+		return messages, nil
+	}
+	prefill := *last
+	messages = messages[:len(messages)-1]
+	return appendTurn(messages, upstreamMessage{Speaker: speakerHuman, Text: fmt.Sprintf(prefillPrompt, prefill.Text)}), nil
+}
+
+// assistantPrefill 对话以 assistant 消息(非工具调用)结束时返回其文本, 作为回复的前缀
+func assistantPrefill(req *model.OpenAIChatCompletionRequest) (string, bool) {
+	if len(req.Messages) == 0 {
+		return "", false
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role != "assistant" || len(last.ToolCalls) > 0 {
+		return "", false
+	}
+	text := messageText(last.Content)
+	return text, strings.TrimSpace(text) != ""
+}
+
+// emulatedPrefill 模型不支持预填充时返回需要从回复开头去除的前缀
+func emulatedPrefill(req *model.OpenAIChatCompletionRequest) string {
+	modelInfo, ok := common.GetSGModelInfo(req.Model)
+	if !ok || modelInfo.SupportsPrefill() {
+		return ""
+	}
+	prefill, _ := assistantPrefill(req)
+	return strings.TrimSpace(prefill)
+}
+
+// prefillTrimmer 去除模型在回复开头重复的预填充文本, 只输出续写部分
+type prefillTrimmer struct {
+	prefix  string
+	content string
+	decided bool
+}
+
+// feed 追加增量文本, 返回可以输出的文本, 无法确定是否重复前缀时暂存
+func (t *prefillTrimmer) feed(delta string) string {
+	if t.decided || t.prefix == "" {
+		return delta
+	}
+	t.content += delta
+	candidate := strings.TrimLeft(t.content, " \t\r\n")
+	switch {
+	case strings.HasPrefix(candidate, t.prefix):
+		t.decided = true
+		return strings.TrimPrefix(candidate, t.prefix)
+	case candidate == "" || strings.HasPrefix(t.prefix, candidate):
+		return ""
+	}
+	t.decided = true
+	return t.content
+}
+
+// flush 上游结束时返回暂存的文本
+func (t *prefillTrimmer) flush() string {
+	if t.decided {
+		return ""
+	}
+	t.decided = true
+	return t.content
+}
+
+// splitOversizedTurns 将超过 maxBytes 的 human 发言拆分为多轮, 各部分带有续接标记, 之间插入 assistant 占位回复
+// 包含图片的发言不拆分, maxBytes 不大于 0 时不处理
+func splitOversizedTurns(messages []upstreamMessage, maxBytes int) []upstreamMessage {
+	if maxBytes <= 0 {
+		return messages
+	}
+	result := make([]upstreamMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.Speaker != speakerHuman || len(message.Parts) > 0 || len(message.Text) <= maxBytes {
+			result = append(result, message)
+			continue
+		}
+		chunks := common.SplitStringByBytes(message.Text, maxBytes)
+		for i, chunk := range chunks {
+			if i == len(chunks)-1 {
+				result = append(result, upstreamMessage{Speaker: speakerHuman, Text: fmt.Sprintf(splitLastPartFormat, i+1, len(chunks)) + chunk})
+				break
+			}
+			result = append(result,
+				upstreamMessage{Speaker: speakerHuman, Text: fmt.Sprintf(splitPartFormat, i+1, len(chunks)) + chunk},
+				upstreamMessage{Speaker: speakerAssistant, Text: fmt.Sprintf(splitAckFormat, i+1, len(chunks))},
+			)
+		}
+	}
+	return result
+}
+
+// appendTurn 追加一轮发言, 与上一轮发言方相同时合并
+func appendTurn(messages []upstreamMessage, message upstreamMessage) []upstreamMessage {
+	if len(messages) == 0 || messages[len(messages)-1].Speaker != message.Speaker {
+		return append(messages, message)
+	}
+
+	last := &messages[len(messages)-1]
+	if len(last.Parts) > 0 || len(message.Parts) > 0 {
+		last.Parts = append(turnParts(*last), turnParts(message)...)
+	}
+	switch {
+	case last.Text == "":
+		last.Text = message.Text
+	case message.Text != "":
+		last.Text += "\n\n" + message.Text
+	}
+	return messages
+}
+
+// turnParts 发言的内容块, 无内容块时将文本转为内容块
+func turnParts(message upstreamMessage) []map[string]interface{} {
+	if len(message.Parts) > 0 {
+		return message.Parts
+	}
+	if message.Text == "" {
+		return nil
+	}
+	return []map[string]interface{}{{"type": "text", "text": message.Text}}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import "strings"
+
+// maxStopSequences 停止序列数量上限
+const maxStopSequences = 4
+
+// stopBuffer 流式匹配停止序列, 末尾可能是停止序列开头的部分暂不输出, 以便匹配跨增量的停止序列
+type stopBuffer struct {
+	stops   []string
+	content string
+	sent    int
+	matched string
+}
+
+// feed 追加增量文本, 返回可以输出的文本, 匹配到停止序列后 stopped 返回 true 且不再输出
+func (b *stopBuffer) feed(delta string) string {
+	if b.stopped() {
+		return ""
+	}
+	b.content += delta
+	end := len(b.content)
+	if index, stop := findStopSequence(b.content[b.sent:], b.stops); index >= 0 {
+		end = b.sent + index
+		b.matched = stop
+	} else {
+		for _, stop := range b.stops {
+			if n := partialSuffixLength(b.content[b.sent:], stop); len(b.content)-n < end {
+				end = len(b.content) - n
+			}
+		}
+	}
+	text := b.content[b.sent:end]
+	b.sent = end
+	return text
+}
+
+// flush 上游结束时返回暂存的剩余文本
+func (b *stopBuffer) flush() string {
+	if b.stopped() {
+		return ""
+	}
+	text := b.content[b.sent:]
+	b.sent = len(b.content)
+	return text
+}
+
+func (b *stopBuffer) stopped() bool {
+	return b.matched != ""
+}
+
+// findStopSequence 查找最先出现的停止序列, 未找到时返回 -1
+func findStopSequence(content string, stops []string) (int, string) {
+	index, matched := -1, ""
+	for _, stop := range stops {
+		if stop == "" {
+			continue
+		}
+		if i := strings.Index(content, stop); i >= 0 && (index < 0 || i < index) {
+			index, matched = i, stop
+		}
+	}
+	return index, matched
+}
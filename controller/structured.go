@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"net/http"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"strings"
+)
+
+const (
+	// structuredOutputMaxAttempts 输出不符合 response_format 时的最大请求次数, 每次重新选择 cookie
+	structuredOutputMaxAttempts = 3
+	responseSchemaURL           = "response_format.json"
+)
+
+// responseFormatPrompt 根据 response_format 生成输出格式说明, 不要求 JSON 时返回空
+func responseFormatPrompt(req *model.OpenAIChatCompletionRequest) string {
+	format := req.ResponseFormat
+	if !format.IsJSON() {
+		return ""
+	}
+	if format.Type == "json_schema" && format.JSONSchema != nil && format.JSONSchema.Schema != nil {
+		schema, _ := json.Marshal(format.JSONSchema.Schema)
+		prompt := fmt.Sprintf("Respond only with a single JSON value that conforms to the following JSON Schema (name: %s):\n%s\n", format.JSONSchema.Name, schema)
+		if format.JSONSchema.Description != "" {
+			prompt += "Schema description: " + format.JSONSchema.Description + "\n"
+		}
+		return prompt + "Do not wrap the JSON in code fences and do not add any text before or after it."
+	}
+	return "Respond only with a single valid JSON object. Do not wrap the JSON in code fences and do not add any text before or after it."
+}
+
+// doStructuredUpstreamChat 请求上游并校验输出是否符合 response_format, 不符合时换 cookie 重试
+// 输出因 max_tokens 截断时重试无法修复, 与 OpenAI 一致直接返回截断的输出, finish_reason 为 length
+func doStructuredUpstreamChat(ctx context.Context, client cycletls.CycleTLS, openAIReq *model.OpenAIChatCompletionRequest) (*upstreamResult, error) {
+	schema, err := compileResponseSchema(openAIReq.ResponseFormat)
+	if err != nil {
+		return nil, &upstreamError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Invalid response_format json_schema: %v", err)}
+	}
+
+	var validateErr error
+	for attempt := 0; attempt < structuredOutputMaxAttempts; attempt++ {
+		// 每次重试后移起始 cookie, 步长为回复数量, 与其他回复使用的 cookie 错开
+		result, err := doUpstreamChat(shiftCookieOffset(ctx, attempt*openAIReq.ChoiceCount()), client, openAIReq, nil)
+		if err != nil {
+			return nil, err
+		}
+		content, err := validateStructuredOutput(result.Content, schema)
+		if err == nil {
+			result.Content = content
+			return result, nil
+		}
+		if result.finishReason() == "length" {
+			logger.Warnf(ctx, "Structured output truncated by max_tokens: %v", err)
+			return result, nil
+		}
+		validateErr = err
+		logger.Warnf(ctx, "Structured output invalid on attempt %d/%d: %v", attempt+1, structuredOutputMaxAttempts, err)
+	}
+	return nil, &upstreamError{StatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("Model output does not match response_format after %d attempts: %v", structuredOutputMaxAttempts, validateErr)}
+}
+
+// compileResponseSchema 编译 json_schema, 仅要求 json_object 时返回 nil
+func compileResponseSchema(format *model.OpenAIResponseFormat) (*jsonschema.Schema, error) {
+	if format == nil || format.Type != "json_schema" || format.JSONSchema == nil || format.JSONSchema.Schema == nil {
+		return nil, nil
+	}
+	doc, err := unmarshalJSONValue(format.JSONSchema.Schema)
+	if err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	// 默认加载器会读取 file:// 等外部地址, 只允许引用 schema 内部定义
+	compiler.UseLoader(rejectLoader{})
+	if err := compiler.AddResource(responseSchemaURL, doc); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(responseSchemaURL)
+}
+
+// rejectLoader 拒绝加载任何外部 $ref
+type rejectLoader struct{}
+
+func (rejectLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("external schema reference %s is not allowed", url)
+}
+
+// validateStructuredOutput 修复并校验输出, 返回规范化后的 JSON
+func validateStructuredOutput(content string, schema *jsonschema.Schema) (string, error) {
+	repaired, ok := repairJSON(content)
+	if !ok {
+		return "", fmt.Errorf("output is not valid JSON")
+	}
+	if schema == nil {
+		// json_object 要求输出 JSON 对象
+		if !strings.HasPrefix(repaired, "{") {
+			return "", fmt.Errorf("output is not a JSON object")
+		}
+		return repaired, nil
+	}
+	value, err := jsonschema.UnmarshalJSON(strings.NewReader(repaired))
+	if err != nil {
+		return "", err
+	}
+	if err := schema.Validate(value); err != nil {
+		return "", err
+	}
+	return repaired, nil
+}
+
+// repairJSON 修复接近 JSON 的输出: 去除代码块标记及前后多余文本、删除尾随逗号
+func repairJSON(content string) (string, bool) {
+	text := strings.TrimSpace(content)
+	if json.Valid([]byte(text)) {
+		return text, true
+	}
+
+	if start := strings.Index(text, "```"); start >= 0 {
+		inner := text[start+3:]
+		if newline := strings.Index(inner, "\n"); newline >= 0 {
+			inner = inner[newline+1:]
+		}
+		if end := strings.LastIndex(inner, "```"); end >= 0 {
+			inner = inner[:end]
+		}
+		text = strings.TrimSpace(inner)
+	}
+
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start < 0 || end < start {
+		return "", false
+	}
+	text = removeTrailingCommas(text[start : end+1])
+	if !json.Valid([]byte(text)) {
+		return "", false
+	}
+	return text, true
+}
+
+// removeTrailingCommas 删除 } 或 ] 前的逗号, 忽略字符串内的内容
+func removeTrailingCommas(text string) string {
+	var buf bytes.Buffer
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			buf.WriteByte(ch)
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		if ch == '"' {
+			inString = true
+		}
+		if ch == ',' {
+			next := strings.TrimLeft(text[i+1:], " \t\r\n")
+			if strings.HasPrefix(next, "}") || strings.HasPrefix(next, "]") {
+				continue
+			}
+		}
+		buf.WriteByte(ch)
+	}
+	return buf.String()
+}
+
+func unmarshalJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(data))
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sourcegraph2api/common/random"
+	"sourcegraph2api/model"
+	"strings"
+)
+
+// 工具调用模拟: 将工具定义写入提示词, 要求模型以固定标签输出调用, 再解析为 tool_calls
+const (
+	toolCallsStartTag = "<tool_calls>"
+	toolCallsEndTag   = "</tool_calls>"
+	toolCallIDFormat  = "call_%s"
+)
+
+// emulatedToolCall 模型输出的工具调用
+type emulatedToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// toolPrompt 根据 tools 及 tool_choice 生成工具说明, 无需调用工具时返回空
+func toolPrompt(req *model.OpenAIChatCompletionRequest) string {
+	if len(req.Tools) == 0 {
+		return ""
+	}
+
+	choice := "auto"
+	var forced string
+	switch v := req.ToolChoice.(type) {
+	case string:
+		choice = v
+	case map[string]interface{}:
+		if function, ok := v["function"].(map[string]interface{}); ok {
+			forced, _ = function["name"].(string)
+		}
+	}
+	if choice == "none" {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("You have access to the following tools. Each tool is described by its name, description and a JSON schema of its parameters:\n\n")
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		parameters, _ := json.Marshal(tool.Function.Parameters)
+		sb.WriteString(fmt.Sprintf("- %s: %s\n  parameters: %s\n", tool.Function.Name, tool.Function.Description, parameters))
+	}
+	sb.WriteString("\nTo call tools, reply with the following block, containing a JSON array of calls, and nothing after it:\n")
+	sb.WriteString(toolCallsStartTag + "\n[{\"name\": \"<tool name>\", \"arguments\": {<arguments matching the schema>}}]\n" + toolCallsEndTag + "\n")
+	sb.WriteString("You may write a short explanation before the block. Tool results will be sent back to you in <tool_result> blocks.\n")
+	switch {
+	case forced != "":
+		sb.WriteString(fmt.Sprintf("You must call the tool %s in this reply.", forced))
+	case choice == "required":
+		sb.WriteString("You must call at least one tool in this reply.")
+	default:
+		sb.WriteString("Only call a tool when it is needed; otherwise answer normally without the block.")
+	}
+	return sb.String()
+}
+
+// toolsEnabled 是否需要解析模型输出中的工具调用
+func toolsEnabled(req *model.OpenAIChatCompletionRequest) bool {
+	return toolPrompt(req) != ""
+}
+
+// toolMessageText 将工具调用及工具结果消息序列化为文本
+func toolMessageText(msg model.OpenAIChatMessage) string {
+	text, _ := msg.Content.(string)
+	if msg.Role == "tool" {
+		return fmt.Sprintf("<tool_result tool_call_id=\"%s\">\n%s\n</tool_result>", msg.ToolCallID, text)
+	}
+	if len(msg.ToolCalls) == 0 {
+		return text
+	}
+
+	calls := make([]emulatedToolCall, 0, len(msg.ToolCalls))
+	for _, call := range msg.ToolCalls {
+		arguments := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(arguments) {
+			arguments = json.RawMessage("{}")
+		}
+		calls = append(calls, emulatedToolCall{Name: call.Function.Name, Arguments: arguments})
+	}
+	jsonCalls, _ := json.Marshal(calls)
+	if text != "" {
+		text += "\n"
+	}
+	return text + toolCallsStartTag + "\n" + string(jsonCalls) + "\n" + toolCallsEndTag
+}
+
+// parseToolCalls 解析模型输出中的工具调用, 返回标签前的文本
+func parseToolCalls(content string) (string, []model.OpenAIToolCall, bool) {
+	start := strings.Index(content, toolCallsStartTag)
+	if start < 0 {
+		return content, nil, false
+	}
+	body := content[start+len(toolCallsStartTag):]
+	if end := strings.Index(body, toolCallsEndTag); end >= 0 {
+		body = body[:end]
+	}
+	body = strings.TrimSpace(body)
+	body = strings.TrimPrefix(strings.TrimSuffix(body, "```"), "```json")
+
+	var calls []emulatedToolCall
+	if err := json.Unmarshal([]byte(strings.TrimSpace(body)), &calls); err != nil {
+		var call emulatedToolCall
+		if err := json.Unmarshal([]byte(strings.TrimSpace(body)), &call); err != nil {
+			return content, nil, false
+		}
+		calls = []emulatedToolCall{call}
+	}
+
+	toolCalls := make([]model.OpenAIToolCall, 0, len(calls))
+	for _, call := range calls {
+		if call.Name == "" {
+			continue
+		}
+		arguments := string(call.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		toolCalls = append(toolCalls, model.OpenAIToolCall{
+			ID:       fmt.Sprintf(toolCallIDFormat, random.GetRandomString(24)),
+			Type:     "function",
+			Function: model.OpenAIFunctionCall{Name: call.Name, Arguments: arguments},
+		})
+	}
+	if len(toolCalls) == 0 {
+		return content, nil, false
+	}
+	return strings.TrimSpace(content[:start]), toolCalls, true
+}
+
+// toolCallStream 流式输出时拦截工具调用标签, 标签前的文本照常输出
+type toolCallStream struct {
+	content string
+	sent    int
+	inCall  bool
+}
+
+// feed 追加增量文本, 返回可以输出的文本及是否已读取到完整的工具调用
+func (s *toolCallStream) feed(delta string) (string, bool) {
+	s.content += delta
+	if !s.inCall {
+		if index := strings.Index(s.content[s.sent:], toolCallsStartTag); index >= 0 {
+			s.inCall = true
+			text := s.content[s.sent : s.sent+index]
+			s.sent += index
+			return text, s.complete()
+		}
+		// 保留可能是标签开头的部分, 待后续增量确认
+		end := len(s.content) - partialSuffixLength(s.content[s.sent:], toolCallsStartTag)
+		text := s.content[s.sent:end]
+		s.sent = end
+		return text, false
+	}
+	return "", s.complete()
+}
+
+func (s *toolCallStream) complete() bool {
+	return s.inCall && strings.Contains(s.content[s.sent:], toolCallsEndTag)
+}
+
+// flush 上游结束时返回剩余文本及解析出的工具调用
+func (s *toolCallStream) flush() (string, []model.OpenAIToolCall) {
+	rest := s.content[s.sent:]
+	s.sent = len(s.content)
+	if !s.inCall {
+		return rest, nil
+	}
+	if _, toolCalls, ok := parseToolCalls(rest); ok {
+		return "", toolCalls
+	}
+	return rest, nil
+}
+
+// partialSuffixLength text 末尾与 marker 开头重合的最大长度
+func partialSuffixLength(text, marker string) int {
+	for n := len(marker) - 1; n > 0; n-- {
+		if strings.HasSuffix(text, marker[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,345 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"net/http"
+	"sourcegraph2api/common"
+	"sourcegraph2api/common/config"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"sourcegraph2api/sourcegraphapi"
+	"strings"
+	"time"
+)
+
+// statusClientClosedRequest 客户端取消请求时的状态码
+const statusClientClosedRequest = 499
+
+// upstreamEvent Sourcegraph 流式事件数据
+type upstreamEvent struct {
+	DeltaText string `json:"deltaText"`
+	// DeltaThinking 思考模型的思考过程增量
+	DeltaThinking string `json:"deltaThinking"`
+	StopReason    string `json:"stopReason"`
+	Error         string `json:"error"`
+	// Usage 上游返回的用量, 部分模型不返回
+	Usage *upstreamUsage `json:"usage"`
+}
+
+type upstreamUsage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// upstreamResult 上游对话结果
+type upstreamResult struct {
+	Content    string
+	StopReason string
+	// Reasoning 思考模型的思考过程, 不参与停止序列匹配
+	Reasoning string
+	Usage     *upstreamUsage
+	// StopSequence 本地匹配到的停止序列, 此时 Content 不包含停止序列及其后的内容
+	StopSequence string
+}
+
+// finishReason 将上游结束原因(end_turn、max_tokens、stop_sequence 等)转换为 OpenAI finish_reason: stop、length 或 content_filter
+func (r *upstreamResult) finishReason() string {
+	switch strings.ToLower(r.StopReason) {
+	case "max_tokens", "length", "max_output_tokens":
+		return "length"
+	case "content_filter", "refusal", "safety":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// stoppedBySequence 上游是否因转发的停止序列结束
+func (r *upstreamResult) stoppedBySequence() bool {
+	return strings.ToLower(r.StopReason) == "stop_sequence"
+}
+
+// isContentFilterError 上游错误是否为内容审核拦截
+func isContentFilterError(message string) bool {
+	message = strings.ToLower(message)
+	for _, keyword := range []string{"content filter", "content_filter", "content policy", "content management policy", "flagged"} {
+		if strings.Contains(message, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamError 上游请求错误, StatusCode 为建议返回给客户端的状态码
+type upstreamError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *upstreamError) Error() string {
+	return e.Message
+}
+
+// deltaHandler 增量文本回调, 返回 false 时停止读取上游数据
+type deltaHandler func(delta string) bool
+
+// doUpstreamChat 向 Sourcegraph 发起对话请求, 解析流式事件并回调增量文本, 思考过程仅记录在结果中
+func doUpstreamChat(ctx context.Context, client cycletls.CycleTLS, openAIReq *model.OpenAIChatCompletionRequest, onDelta deltaHandler) (*upstreamResult, error) {
+	return doUpstreamReasoningChat(ctx, client, openAIReq, nil, onDelta)
+}
+
+// doUpstreamReasoningChat 同 doUpstreamChat, onReasoning 不为空时回调思考过程增量
+// 开启 AutoContinue 时, 回复因 max_tokens 截断后自动续写并拼接到同一结果
+func doUpstreamReasoningChat(ctx context.Context, client cycletls.CycleTLS, openAIReq *model.OpenAIChatCompletionRequest, onReasoning, onDelta deltaHandler) (*upstreamResult, error) {
+	result, err := doUpstreamRound(ctx, client, openAIReq, onReasoning, onDelta)
+	if err != nil || !openAIReq.AutoContinue {
+		return result, err
+	}
+	return doContinuation(ctx, client, openAIReq, result, onReasoning, onDelta), nil
+}
+
+// doUpstreamRound 发起一次上游对话请求
+// 请求携带停止序列时在本地匹配, 匹配到后提前结束上游请求
+func doUpstreamRound(ctx context.Context, client cycletls.CycleTLS, openAIReq *model.OpenAIChatCompletionRequest, onReasoning, onDelta deltaHandler) (*upstreamResult, error) {
+	var result *upstreamResult
+	var stops *stopBuffer
+	var prefill *prefillTrimmer
+	var eventErr error
+	aborted := false
+	// 超出上下文窗口时按默认策略裁剪, 每轮只处理一次, 切换 cookie 重试时复用
+	upstreamReq, err := fitContextWindow(openAIReq)
+	if err != nil {
+		return nil, &upstreamError{StatusCode: http.StatusBadRequest, Message: err.Error()}
+	}
+	request := func(ctx context.Context, cookie string) (<-chan cycletls.SSEResponse, error) {
+		// 每次重试重新开始累计结果
+		result = &upstreamResult{}
+		stops = &stopBuffer{stops: openAIReq.StopSequences()}
+		prefill = &prefillTrimmer{prefix: emulatedPrefill(openAIReq)}
+		requestBody, err := createRequestBody(ctx, upstreamReq)
+		if err != nil {
+			if _, ok := err.(*upstreamError); ok {
+				return nil, err
+			}
+			return nil, &upstreamError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+		}
+		jsonData, err := json.Marshal(requestBody)
+		if err != nil {
+			return nil, &upstreamError{StatusCode: http.StatusInternalServerError, Message: "Failed to marshal request body"}
+		}
+		return sourcegraphapi.MakeStreamChatRequest(ctx, client, jsonData, cookie)
+	}
+
+	// emitContent 匹配停止序列后输出正文增量, 返回 false 时停止读取上游数据
+	emitContent := func(delta string) bool {
+		if text := stops.feed(delta); text != "" {
+			result.Content += text
+			if onDelta != nil && !onDelta(text) {
+				aborted = true
+				return false
+			}
+		}
+		if stops.stopped() {
+			result.StopReason = "stop_sequence"
+			result.StopSequence = stops.matched
+			return false
+		}
+		return true
+	}
+
+	err = doUpstreamStream(ctx, openAIReq.Model, request, func(data string) bool {
+		// 跳过 "event: completion" 等非数据行
+		if !strings.HasPrefix(data, "{") {
+			return true
+		}
+
+		var event upstreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			logger.Errorf(ctx, "Failed to unmarshal event: %v", err)
+			return true
+		}
+		if event.Error != "" {
+			// 内容审核拦截作为正常结束返回, finish_reason 为 content_filter
+			if isContentFilterError(event.Error) {
+				logger.Warnf(ctx, "Upstream content filtered: %s", event.Error)
+				result.StopReason = "content_filter"
+				return false
+			}
+			eventErr = &upstreamError{StatusCode: http.StatusInternalServerError, Message: event.Error}
+			return false
+		}
+		if event.StopReason != "" {
+			result.StopReason = event.StopReason
+		}
+		if event.Usage != nil {
+			result.Usage = event.Usage
+		}
+		if event.DeltaThinking != "" {
+			result.Reasoning += event.DeltaThinking
+			if onReasoning != nil && !onReasoning(event.DeltaThinking) {
+				aborted = true
+				return false
+			}
+		}
+		// 模拟预填充时去除模型重复输出的前缀
+		if text := prefill.feed(event.DeltaText); text != "" {
+			return emitContent(text)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if eventErr != nil {
+		return nil, eventErr
+	}
+	if !aborted && !stops.stopped() {
+		if text := prefill.flush(); text != "" {
+			emitContent(text)
+		}
+	}
+	// 输出暂存的可能是停止序列开头的文本
+	if !aborted {
+		if text := stops.flush(); text != "" {
+			result.Content += text
+			if onDelta != nil {
+				onDelta(text)
+			}
+		}
+	}
+	return result, nil
+}
+
+// doUpstreamStream 使用 cookie 池发起上游流式请求, 遇到限流或 cookie 失效时自动切换下一个 cookie 重试
+// request 使用指定 cookie 发起请求, onData 接收每行上游数据, 返回 false 时停止读取并中断上游连接
+func doUpstreamStream(ctx context.Context, modelName string, request upstreamRequest, onData func(data string) bool) error {
+	cookieManager := config.NewCookieManager()
+	maxRetries := len(cookieManager.Cookies)
+	cookie, err := firstCookie(ctx, cookieManager)
+	if err != nil {
+		return &upstreamError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		isRateLimit, err := doUpstreamAttempt(ctx, modelName, request, onData, cookie, attempt, maxRetries)
+		if err != nil {
+			return err
+		}
+		if !isRateLimit {
+			return nil
+		}
+
+		// 获取下一个可用的cookie继续尝试
+		cookie, err = cookieManager.GetNextCookie()
+		if err != nil {
+			logger.Errorf(ctx, "No more valid cookies available after attempt %d", attempt+1)
+			return &upstreamError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+		}
+	}
+
+	logger.Errorf(ctx, "All cookies exhausted after %d attempts", maxRetries)
+	return &upstreamError{StatusCode: http.StatusInternalServerError, Message: "All cookies are temporarily unavailable."}
+}
+
+// cookieOffsetKey ctx 中指定的起始 cookie 位置, 并行生成多个回复时使各请求分散到不同 cookie
+type cookieOffsetKey struct{}
+
+// firstCookie 获取首次请求使用的 cookie, 未指定起始位置时随机选择
+func firstCookie(ctx context.Context, cookieManager *config.CookieManager) (string, error) {
+	if offset, ok := ctx.Value(cookieOffsetKey{}).(int); ok {
+		return cookieManager.GetCookieAt(offset)
+	}
+	return cookieManager.GetRandomCookie()
+}
+
+// shiftCookieOffset 将 ctx 中的起始 cookie 位置后移 step, 未指定起始位置时每次请求随机选择, 无需处理
+func shiftCookieOffset(ctx context.Context, step int) context.Context {
+	if offset, ok := ctx.Value(cookieOffsetKey{}).(int); ok && step != 0 {
+		return context.WithValue(ctx, cookieOffsetKey{}, offset+step)
+	}
+	return ctx
+}
+
+// upstreamRequest 使用指定 cookie 发起上游请求, ctx 取消时中断请求
+type upstreamRequest func(ctx context.Context, cookie string) (<-chan cycletls.SSEResponse, error)
+
+// doUpstreamAttempt 使用单个 cookie 读取上游数据, 返回是否需要切换 cookie 重试
+// 返回时取消本次请求的 ctx, 以中断提前结束读取的上游连接
+func doUpstreamAttempt(ctx context.Context, modelName string, request upstreamRequest, onData func(data string) bool, cookie string, attempt, maxRetries int) (bool, error) {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sseChan, err := request(attemptCtx, cookie)
+	if err != nil {
+		logger.Errorf(ctx, "MakeStreamChatRequest err on attempt %d: %v", attempt+1, err)
+		if _, ok := err.(*upstreamError); ok {
+			return false, err
+		}
+		return false, &upstreamError{StatusCode: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	for response := range sseChan {
+
+		if response.Status == 400 {
+			logger.Errorf(ctx, fmt.Sprintf("No permission to call this model:%s", modelName))
+			return true, nil
+		}
+		if response.Status == 429 {
+			logger.Warnf(ctx, "Cookie rate limited, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
+			config.AddRateLimitCookie(cookie, time.Now().Add(time.Duration(config.RateLimitCookieLockDuration)*time.Second))
+			return true, nil
+		}
+		if response.Status == 401 {
+			logger.Warnf(ctx, "Cookie unauthorized, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
+			return true, nil
+		}
+
+		if response.Done {
+			logger.Debugf(ctx, response.Data)
+			if response.Status >= 400 {
+				return false, &upstreamError{StatusCode: response.Status, Message: response.Data}
+			}
+			return false, nil
+		}
+
+		data := strings.TrimSpace(response.Data)
+		if data == "" {
+			continue
+		}
+
+		logger.Debug(ctx, data)
+
+		switch {
+		case common.IsCloudflareChallenge(data):
+			return false, &upstreamError{StatusCode: http.StatusInternalServerError, Message: "cf challenge"}
+		case common.IsRateLimit(data):
+			logger.Warnf(ctx, "Cookie rate limited, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
+			config.AddRateLimitCookie(cookie, time.Now().Add(time.Duration(config.RateLimitCookieLockDuration)*time.Second))
+			return true, nil
+		case common.IsNotLogin(data):
+			logger.Warnf(ctx, "Cookie Not Login, switching to next cookie, attempt %d/%d, COOKIE:%s", attempt+1, maxRetries, cookie)
+			return true, nil
+		}
+
+		if !onData(data) {
+			return false, nil
+		}
+	}
+
+	// 上游连接因 ctx 取消而中断
+	if ctx.Err() != nil {
+		return false, &upstreamError{StatusCode: statusClientClosedRequest, Message: ctx.Err().Error()}
+	}
+	return false, nil
+}
+
+// upstreamErrorStatus 获取错误对应的 HTTP 状态码
+func upstreamErrorStatus(err error) int {
+	if e, ok := err.(*upstreamError); ok {
+		return e.StatusCode
+	}
+	return http.StatusInternalServerError
+}
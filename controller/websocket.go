@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/deanxv/CycleTLS/cycletls"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/url"
+	"sourcegraph2api/common/config"
+	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: checkWebSocketOrigin,
+}
+
+// checkWebSocketOrigin 允许无 Origin 的非浏览器客户端、同源页面、浏览器插件及 WS_ALLOWED_ORIGINS 中的来源
+// 浏览器跨域连接 WebSocket 不受同源策略限制, 限制来源以免任意网页使用浏览器中保存的 ?key= 连接
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	switch u.Scheme {
+	case "chrome-extension", "moz-extension", "safari-web-extension":
+		return true
+	}
+	for _, allowed := range config.WebSocketAllowedOrigins {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// wsSession 单个 WebSocket 连接, 管理其上并发进行的生成
+type wsSession struct {
+	conn        *websocket.Conn
+	client      cycletls.CycleTLS
+	writeMu     sync.Mutex
+	mu          sync.Mutex
+	generations map[string]context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// ChatForWebSocket @Summary WebSocket对话接口
+// @Description 单个长连接上并发进行多个以 id 区分的生成, 发送 {"type":"cancel","id":"..."} 可中断对应上游请求
+// @Tags OpenAI
+// @Param key query string false "API-KEY(浏览器无法设置请求头时使用)"
+// @Param Authorization header string false "Authorization API-KEY"
+// @Router /v1/chat/ws [get]
+func ChatForWebSocket(c *gin.Context) {
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "websocket upgrade err: %v", err)
+		return
+	}
+
+	session := &wsSession{
+		conn:        conn,
+		client:      cycletls.Init(),
+		generations: make(map[string]context.CancelFunc),
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer func() {
+		// 连接断开时中断所有进行中的生成
+		cancel()
+		session.wg.Wait()
+		safeClose(session.client)
+		_ = conn.Close()
+	}()
+
+	for {
+		var frame model.WebSocketClientFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Warnf(ctx, "websocket read err: %v", err)
+			}
+			return
+		}
+
+		switch frame.Type {
+		case "chat":
+			session.start(ctx, frame)
+		case "cancel":
+			session.cancel(frame.ID)
+		default:
+			session.sendError(frame.ID, "invalid_request_error", "invalid_frame_type", fmt.Sprintf("Unknown frame type %s", frame.Type))
+		}
+	}
+}
+
+// start 在新协程中开始一次生成
+func (s *wsSession) start(ctx context.Context, frame model.WebSocketClientFrame) {
+	if frame.ID == "" || frame.Request == nil {
+		s.sendError(frame.ID, "invalid_request_error", "invalid_request", "Frame id and request are required")
+		return
+	}
+
+	s.mu.Lock()
+	if _, ok := s.generations[frame.ID]; ok {
+		s.mu.Unlock()
+		s.sendError(frame.ID, "invalid_request_error", "duplicate_id", fmt.Sprintf("Generation %s is already running", frame.ID))
+		return
+	}
+	genCtx, cancel := context.WithCancel(ctx)
+	s.generations[frame.ID] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.finish(frame.ID)
+		s.generate(genCtx, frame.ID, *frame.Request)
+	}()
+}
+
+// generate 按 HTTP 接口相同的流程校验请求并生成, 分片以 delta 帧发送
+func (s *wsSession) generate(ctx context.Context, id string, openAIReq model.OpenAIChatCompletionRequest) {
+	if _, reqErr := prepareChatRequest(ctx, s.client, &openAIReq, chatRequestOptions{}); reqErr != nil {
+		s.sendError(id, reqErr.Type, reqErr.Code, reqErr.Message)
+		return
+	}
+
+	responseId := fmt.Sprintf(responseIDFormat, time.Now().Format("20060102150405"))
+	results, err := streamChatChoices(ctx, s.client, &openAIReq, responseId, func(response model.OpenAIChatCompletionResponse) error {
+		return s.send(model.WebSocketServerFrame{Type: "delta", ID: id, Data: &response})
+	})
+	if ctx.Err() != nil {
+		_ = s.send(model.WebSocketServerFrame{Type: "cancelled", ID: id})
+		return
+	}
+	if err != nil {
+		s.sendError(id, "upstream_error", fmt.Sprintf("%d", upstreamErrorStatus(err)), err.Error())
+		return
+	}
+
+	// 各回复的 finish_reason 在 delta 帧中返回, done 帧携带本次生成的用量
+	usage := createUsageResponse(responseId, openAIReq.Model, chatUsage(&openAIReq, results))
+	_ = s.send(model.WebSocketServerFrame{Type: "done", ID: id, Data: &usage})
+}
+
+// cancel 取消生成, 中断对应的上游请求
+func (s *wsSession) cancel(id string) {
+	s.mu.Lock()
+	cancel, ok := s.generations[id]
+	s.mu.Unlock()
+	if !ok {
+		s.sendError(id, "invalid_request_error", "generation_not_found", fmt.Sprintf("Generation %s not found", id))
+		return
+	}
+	cancel()
+}
+
+func (s *wsSession) finish(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.generations[id]; ok {
+		cancel()
+		delete(s.generations, id)
+	}
+}
+
+// send 发送服务端帧, 同一连接的写操作需串行
+func (s *wsSession) send(frame model.WebSocketServerFrame) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.conn.WriteJSON(frame)
+}
+
+func (s *wsSession) sendError(id, errorType, code, message string) {
+	_ = s.send(model.WebSocketServerFrame{
+		Type: "error",
+		ID:   id,
+		Error: &model.OpenAIError{
+			Message: message,
+			Type:    errorType,
+			Code:    code,
+		},
+	})
+}
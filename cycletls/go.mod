@@ -0,0 +1,23 @@
+module github.com/deanxv/CycleTLS/cycletls
+
+go 1.21
+
+toolchain go1.21.3
+
+require (
+	github.com/Danny-Dasilva/fhttp v0.0.0-20240217042913-eeeb0b347ce1
+	github.com/andybalholm/brotli v1.1.0
+	github.com/gorilla/websocket v1.5.1
+	github.com/refraction-networking/utls v1.6.2
+	golang.org/x/net v0.21.0
+	h12.io/socks v1.0.3
+)
+
+require (
+	github.com/cloudflare/circl v1.3.7 // indirect
+	github.com/klauspost/compress v1.17.6 // indirect
+	github.com/quic-go/quic-go v0.41.0 // indirect
+	golang.org/x/crypto v0.19.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
@@ -2,6 +2,7 @@ package cycletls
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -425,7 +426,7 @@ type SSEResponse struct {
 //	resp, err := res.client.Do(res.req)
 //	if err != nil {
 //		parsedError := parseError(err)
-//		sseChan <- SSEResponse{
+//		sendSSE(ctx, sseChan, SSEResponse{
 //			RequestID: res.options.RequestID,
 //			Status:    parsedError.StatusCode,
 //			Data:      parsedError.ErrorMsg + "-> \n" + string(err.Error()),
@@ -593,21 +594,22 @@ type SSEResponse struct {
 //	}
 //}
 
-func dispatcherSSE(res fullRequest, sseChan chan<- SSEResponse) {
+func dispatcherSSE(ctx context.Context, res fullRequest, sseChan chan<- SSEResponse) {
 	defer res.client.CloseIdleConnections()
 
 	finalUrl := res.options.Options.URL
 
-	resp, err := res.client.Do(res.req)
+	// ctx 取消时中断连接, 正在进行的读取立即返回错误
+	resp, err := res.client.Do(res.req.WithContext(ctx))
 	if err != nil {
 		parsedError := parseError(err)
-		sseChan <- SSEResponse{
+		sendSSE(ctx, sseChan, SSEResponse{
 			RequestID: res.options.RequestID,
 			Status:    parsedError.StatusCode,
 			Data:      fmt.Sprintf("%s-> \n%s", parsedError.ErrorMsg, err.Error()),
 			Done:      true,
 			FinalUrl:  finalUrl,
-		}
+		})
 		return
 	}
 	defer resp.Body.Close()
@@ -620,13 +622,13 @@ func dispatcherSSE(res fullRequest, sseChan chan<- SSEResponse) {
 			errorMsg = fmt.Sprintf("HTTP error status: %d", resp.StatusCode)
 		}
 
-		sseChan <- SSEResponse{
+		sendSSE(ctx, sseChan, SSEResponse{
 			RequestID: res.options.RequestID,
 			Status:    resp.StatusCode,
 			Data:      errorMsg,
 			Done:      true,
 			FinalUrl:  finalUrl,
-		}
+		})
 		return
 	}
 
@@ -646,6 +648,10 @@ func dispatcherSSE(res fullRequest, sseChan chan<- SSEResponse) {
 			if err == io.EOF {
 				break
 			}
+			// ctx 取消导致的读取错误无需重试
+			if ctx.Err() != nil {
+				return
+			}
 
 			if retries < maxRetries {
 				retries++
@@ -653,13 +659,13 @@ func dispatcherSSE(res fullRequest, sseChan chan<- SSEResponse) {
 				continue
 			}
 
-			sseChan <- SSEResponse{
+			sendSSE(ctx, sseChan, SSEResponse{
 				RequestID: res.options.RequestID,
 				Status:    resp.StatusCode,
 				Data:      "Error reading stream: " + err.Error(),
 				Done:      true,
 				FinalUrl:  finalUrl,
-			}
+			})
 			return
 		}
 
@@ -675,37 +681,54 @@ func dispatcherSSE(res fullRequest, sseChan chan<- SSEResponse) {
 		}
 
 		// 处理数据行
-		//if strings.HasPrefix(line, "data: ") {
-		data := strings.TrimSpace(strings.TrimPrefix(line, "data: "))
-		if data != "" {
-			sseChan <- SSEResponse{
-				RequestID: res.options.RequestID,
-				Status:    resp.StatusCode,
-				Data:      data,
-				Done:      false,
-				FinalUrl:  finalUrl,
+		if strings.HasPrefix(line, "data: ") {
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+			if data != "" {
+				if !sendSSE(ctx, sseChan, SSEResponse{
+					RequestID: res.options.RequestID,
+					Status:    resp.StatusCode,
+					Data:      data,
+					Done:      false,
+					FinalUrl:  finalUrl,
+				}) {
+					return
+				}
 			}
 		}
-		//}
 
 		// 检查是否有结束标记
-		if strings.Contains(line, "[DONE]") {
+		if strings.HasSuffix(line, "[DONE]") {
 			break
 		}
 	}
 
 	// 发送完成信号
-	sseChan <- SSEResponse{
+	sendSSE(ctx, sseChan, SSEResponse{
 		RequestID: res.options.RequestID,
 		Status:    resp.StatusCode,
-		Data:      "[DONE]",
+		Data:      "",
 		Done:      true,
 		FinalUrl:  finalUrl,
+	})
+}
+
+// sendSSE 发送 SSE 数据, ctx 取消后返回 false
+func sendSSE(ctx context.Context, sseChan chan<- SSEResponse, response SSEResponse) bool {
+	select {
+	case sseChan <- response:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
 // 修改 Do 方法以支持 SSE
 func (client CycleTLS) DoSSE(URL string, options Options, Method string) (<-chan SSEResponse, error) {
+	return client.DoSSEContext(context.Background(), URL, options, Method)
+}
+
+// DoSSEContext 同 DoSSE, ctx 取消时中断上游连接并关闭返回的通道
+func (client CycleTLS) DoSSEContext(ctx context.Context, URL string, options Options, Method string) (<-chan SSEResponse, error) {
 	sseChan := make(chan SSEResponse)
 
 	options.URL = URL
@@ -722,7 +745,7 @@ func (client CycleTLS) DoSSE(URL string, options Options, Method string) (<-chan
 
 	go func() {
 		defer close(sseChan)
-		dispatcherSSE(res, sseChan)
+		dispatcherSSE(ctx, res, sseChan)
 	}()
 
 	return sseChan, nil
@@ -5,13 +5,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"net"
 	"os"
 	"sourcegraph2api/check"
 	"sourcegraph2api/common"
 	"sourcegraph2api/common/config"
 	logger "sourcegraph2api/common/loggger"
+	"sourcegraph2api/controller"
 	"sourcegraph2api/middleware"
 	"sourcegraph2api/model"
 	"sourcegraph2api/router"
@@ -21,6 +24,10 @@ import (
 //var buildFS embed.FS
 
 func main() {
+	if *common.McpStdio {
+		// stdout 用于 MCP 消息, 日志输出至 stderr
+		gin.DefaultWriter = os.Stderr
+	}
 	logger.SetupLogger()
 	logger.SysLog(fmt.Sprintf("sourcegraph2api %s starting...", common.Version))
 	check.CheckEnvVariable()
@@ -34,6 +41,14 @@ func main() {
 	model.InitTokenEncoders()
 	config.InitSGCookies()
 
+	if *common.McpStdio {
+		logger.SysLog("sourcegraph2api running as MCP server over stdio")
+		if err = controller.ServeMCPStdio(context.Background(), os.Stdin, os.Stdout); err != nil {
+			logger.FatalLog("MCP stdio server error: " + err.Error())
+		}
+		return
+	}
+
 	server := gin.New()
 	server.Use(gin.Recovery())
 	server.Use(middleware.RequestId())
@@ -47,6 +62,10 @@ func main() {
 		port = strconv.Itoa(*common.Port)
 	}
 
+	if config.GrpcPort != "" {
+		go startGrpcServer(config.GrpcPort)
+	}
+
 	if config.DebugEnabled {
 		logger.SysLog("running in DEBUG mode.")
 	}
@@ -60,3 +79,14 @@ func main() {
 	}
 
 }
+
+func startGrpcServer(port string) {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		logger.FatalLog("failed to listen gRPC port: " + err.Error())
+	}
+	logger.SysLog("gRPC server listening on :" + port)
+	if err = router.NewGrpcServer().Serve(listener); err != nil {
+		logger.FatalLog("failed to start gRPC server: " + err.Error())
+	}
+}
@@ -30,6 +30,23 @@ func authHelper(c *gin.Context) {
 func authHelperForOpenai(c *gin.Context) {
 	secret := c.Request.Header.Get("Authorization")
 	secret = strings.Replace(secret, "Bearer ", "", 1)
+	// 兼容 Cody 客户端的 "token xxx"
+	secret = strings.TrimPrefix(secret, "token ")
+	// 兼容 Anthropic 风格的 x-api-key
+	if secret == "" {
+		secret = c.Request.Header.Get("x-api-key")
+	}
+	// 兼容 Azure 风格的 api-key
+	if secret == "" {
+		secret = c.Request.Header.Get("api-key")
+	}
+	// 兼容 Gemini 风格的 x-goog-api-key 及 ?key=
+	if secret == "" {
+		secret = c.Request.Header.Get("x-goog-api-key")
+	}
+	if secret == "" {
+		secret = c.Query("key")
+	}
 	if isValidSecret(secret) {
 		c.JSON(http.StatusUnauthorized, model.OpenAIErrorResponse{
 			OpenAIError: model.OpenAIError{
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sourcegraph2api/common/helper"
+	"strings"
+)
+
+// grpcAuthHelper 校验 metadata 中的 authorization 并注入请求 ID
+func grpcAuthHelper(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var secret string
+	if values := md.Get("authorization"); len(values) > 0 {
+		secret = strings.Replace(values[0], "Bearer ", "", 1)
+		secret = strings.TrimPrefix(secret, "token ")
+	}
+	if isValidSecret(secret) {
+		return nil, status.Error(codes.Unauthenticated, "authorization(api-secret)校验失败")
+	}
+
+	id := helper.GenRequestID()
+	_ = grpc.SetHeader(ctx, metadata.Pairs(helper.RequestIdKey, id))
+	return context.WithValue(ctx, helper.RequestIdKey, id), nil
+}
+
+// grpcServerStream 替换 ServerStream 的 context
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func GrpcUnaryAuth() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := grpcAuthHelper(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func GrpcStreamAuth() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := grpcAuthHelper(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
@@ -0,0 +1,260 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type AnthropicMessagesRequest struct {
+	Model         string             `json:"model"`
+	Messages      []AnthropicMessage `json:"messages"`
+	System        interface{}        `json:"system"`
+	MaxTokens     int                `json:"max_tokens"`
+	StopSequences []string           `json:"stop_sequences"`
+	Stream        bool               `json:"stream"`
+	Temperature   float64            `json:"temperature"`
+	Thinking      *AnthropicThinking `json:"thinking,omitempty"`
+}
+
+// AnthropicThinking Type 为 enabled 或 disabled
+type AnthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// ReasoningEffort 按思考预算转换为推理强度
+func (t *AnthropicThinking) ReasoningEffort() string {
+	switch {
+	case t == nil:
+		return ""
+	case t.Type == "disabled":
+		return "none"
+	case t.BudgetTokens <= 0:
+		return "medium"
+	case t.BudgetTokens < 4096:
+		return "low"
+	case t.BudgetTokens < 16384:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+type AnthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// AnthropicContentBlock Type 为 text 或 thinking
+type AnthropicContentBlock struct {
+	Type      string `json:"type"`
+	Text      string `json:"text"`
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// MarshalJSON 按类型输出字段, thinking 块输出 thinking 及 signature, 其余输出 text
+func (b AnthropicContentBlock) MarshalJSON() ([]byte, error) {
+	if b.Type == "thinking" {
+		return json.Marshal(struct {
+			Type      string `json:"type"`
+			Thinking  string `json:"thinking"`
+			Signature string `json:"signature"`
+		}{b.Type, b.Thinking, b.Signature})
+	}
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}{b.Type, b.Text})
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type AnthropicMessagesResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []AnthropicContentBlock `json:"content"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+type AnthropicStreamEvent struct {
+	Type         string                     `json:"type"`
+	Message      *AnthropicMessagesResponse `json:"message,omitempty"`
+	Index        *int                       `json:"index,omitempty"`
+	ContentBlock *AnthropicContentBlock     `json:"content_block,omitempty"`
+	Delta        interface{}                `json:"delta,omitempty"`
+	Usage        *AnthropicUsage            `json:"usage,omitempty"`
+}
+
+type AnthropicTextDelta struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type AnthropicThinkingDelta struct {
+	Type     string `json:"type"`
+	Thinking string `json:"thinking"`
+}
+
+type AnthropicMessageDelta struct {
+	StopReason   *string `json:"stop_reason"`
+	StopSequence *string `json:"stop_sequence"`
+}
+
+type AnthropicErrorResponse struct {
+	Type  string         `json:"type"`
+	Error AnthropicError `json:"error"`
+}
+
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// ToOpenAIRequest 转换为 OpenAI 对话请求
+// image 块转换为 image_url 内容块, tool_use 转换为 tool_calls, tool_result 转换为 tool 消息
+// 历史 thinking 块忽略, 其余不支持的内容块返回错误
+func (r *AnthropicMessagesRequest) ToOpenAIRequest() (OpenAIChatCompletionRequest, error) {
+	openAIReq := OpenAIChatCompletionRequest{
+		Model:       r.Model,
+		Stream:      r.Stream,
+		MaxTokens:   r.MaxTokens,
+		Temperature: r.Temperature,
+		Stop:        r.StopSequences,
+	}
+
+	if system := anthropicContentText(r.System); system != "" {
+		openAIReq.Messages = append(openAIReq.Messages, OpenAIChatMessage{
+			Role:    "system",
+			Content: system,
+		})
+	}
+
+	for i, msg := range r.Messages {
+		messages, err := anthropicMessageToOpenAI(msg)
+		if err != nil {
+			return openAIReq, fmt.Errorf("messages.%d: %v", i, err)
+		}
+		openAIReq.Messages = append(openAIReq.Messages, messages...)
+	}
+
+	return openAIReq, nil
+}
+
+// anthropicMessageToOpenAI 转换单条消息, tool_result 块拆分为 tool 消息并放在其余内容之前
+func anthropicMessageToOpenAI(msg AnthropicMessage) ([]OpenAIChatMessage, error) {
+	blocks, ok := msg.Content.([]interface{})
+	if !ok {
+		return []OpenAIChatMessage{{Role: msg.Role, Content: anthropicContentText(msg.Content)}}, nil
+	}
+
+	var messages []OpenAIChatMessage
+	var texts []string
+	var parts []interface{}
+	var toolCalls []OpenAIToolCall
+	hasImage := false
+	for j, it := range blocks {
+		block, ok := it.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("content.%d: expected an object", j)
+		}
+		switch block["type"] {
+		case "text":
+			text, _ := block["text"].(string)
+			texts = append(texts, text)
+			parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+		case "image":
+			imageURL, err := anthropicImageURL(block["source"])
+			if err != nil {
+				return nil, fmt.Errorf("content.%d: %v", j, err)
+			}
+			hasImage = true
+			parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": imageURL}})
+		case "tool_use":
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			input, err := json.Marshal(block["input"])
+			if err != nil {
+				return nil, fmt.Errorf("content.%d: invalid tool_use input", j)
+			}
+			toolCalls = append(toolCalls, OpenAIToolCall{
+				ID:       id,
+				Type:     "function",
+				Function: OpenAIFunctionCall{Name: name, Arguments: string(input)},
+			})
+		case "tool_result":
+			id, _ := block["tool_use_id"].(string)
+			content := anthropicContentText(block["content"])
+			if isError, _ := block["is_error"].(bool); isError {
+				content = "Error: " + content
+			}
+			messages = append(messages, OpenAIChatMessage{Role: "tool", ToolCallID: id, Content: content})
+		case "thinking", "redacted_thinking":
+			// 历史回复的思考过程不再发送给模型
+		default:
+			return nil, fmt.Errorf("content.%d: unsupported content block type %v", j, block["type"])
+		}
+	}
+
+	message := OpenAIChatMessage{Role: msg.Role, Content: strings.Join(texts, "\n"), ToolCalls: toolCalls}
+	if hasImage {
+		message.Content = parts
+	}
+	if len(texts) > 0 || hasImage || len(toolCalls) > 0 {
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// anthropicImageURL 将 image 块的 source 转换为 data URL 或 http(s) URL
+func anthropicImageURL(source interface{}) (string, error) {
+	src, ok := source.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("image source is required")
+	}
+	switch src["type"] {
+	case "base64":
+		mediaType, _ := src["media_type"].(string)
+		data, _ := src["data"].(string)
+		if mediaType == "" || data == "" {
+			return "", fmt.Errorf("image source media_type and data are required")
+		}
+		return fmt.Sprintf("data:%s;base64,%s", mediaType, data), nil
+	case "url":
+		url, _ := src["url"].(string)
+		if url == "" {
+			return "", fmt.Errorf("image source url is required")
+		}
+		return url, nil
+	}
+	return "", fmt.Errorf("unsupported image source type %v", src["type"])
+}
+
+// anthropicContentText 提取 content (字符串或内容块数组) 中的文本
+func anthropicContentText(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var texts []string
+		for _, it := range v {
+			block, ok := it.(map[string]interface{})
+			if !ok || block["type"] != "text" {
+				continue
+			}
+			if text, ok := block["text"].(string); ok {
+				texts = append(texts, text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package model
+
+type AzureErrorResponse struct {
+	Error AzureError `json:"error"`
+}
+
+type AzureError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type AzureContentFilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity"`
+}
+
+type AzureContentFilterResults struct {
+	Hate     AzureContentFilterResult `json:"hate"`
+	SelfHarm AzureContentFilterResult `json:"self_harm"`
+	Sexual   AzureContentFilterResult `json:"sexual"`
+	Violence AzureContentFilterResult `json:"violence"`
+}
+
+type AzurePromptFilterResult struct {
+	PromptIndex          int                       `json:"prompt_index"`
+	ContentFilterResults AzureContentFilterResults `json:"content_filter_results"`
+}
@@ -0,0 +1,86 @@
+package model
+
+type OpenAICompletionRequest struct {
+	Model       string      `json:"model"`
+	Prompt      interface{} `json:"prompt"`
+	Suffix      string      `json:"suffix"`
+	MaxTokens   int         `json:"max_tokens"`
+	Temperature float64     `json:"temperature"`
+	Stream      bool        `json:"stream"`
+	Echo        bool        `json:"echo"`
+	Stop        interface{} `json:"stop"`
+}
+
+type OpenAICompletionResponse struct {
+	ID      string                   `json:"id"`
+	Object  string                   `json:"object"`
+	Created int64                    `json:"created"`
+	Model   string                   `json:"model"`
+	Choices []OpenAICompletionChoice `json:"choices"`
+	Usage   *OpenAIUsage             `json:"usage,omitempty"`
+}
+
+type OpenAICompletionChoice struct {
+	Text         string  `json:"text"`
+	Index        int     `json:"index"`
+	LogProbs     *string `json:"logprobs"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+// Prompts 获取 prompt 列表, prompt 为数组时每个元素对应一个 choice
+func (r *OpenAICompletionRequest) Prompts() []string {
+	switch v := r.Prompt.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var prompts []string
+		for _, it := range v {
+			if prompt, ok := it.(string); ok {
+				prompts = append(prompts, prompt)
+			}
+		}
+		return prompts
+	}
+	return nil
+}
+
+// StopSequences 获取停止序列, stop 可为字符串或字符串数组
+func (r *OpenAICompletionRequest) StopSequences() []string {
+	return stopSequences(r.Stop)
+}
+
+// ToOpenAIRequest 将单个 prompt 包装为一轮 human 对话
+func (r *OpenAICompletionRequest) ToOpenAIRequest(content string) OpenAIChatCompletionRequest {
+	return OpenAIChatCompletionRequest{
+		Model:       r.Model,
+		Stream:      r.Stream,
+		MaxTokens:   r.MaxTokens,
+		Temperature: r.Temperature,
+		Stop:        r.Stop,
+		Messages: []OpenAIChatMessage{{
+			Role:    "user",
+			Content: content,
+		}},
+	}
+}
+
+func stopSequences(stop interface{}) []string {
+	switch v := stop.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var stops []string
+		for _, it := range v {
+			if s, ok := it.(string); ok && s != "" {
+				stops = append(stops, s)
+			}
+		}
+		return stops
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+type GeminiGenerateContentRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
+}
+
+type GeminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type GeminiGenerationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens"`
+	Temperature     float64  `json:"temperature"`
+	StopSequences   []string `json:"stopSequences"`
+}
+
+type GeminiGenerateContentResponse struct {
+	Candidates    []GeminiCandidate   `json:"candidates"`
+	UsageMetadata GeminiUsageMetadata `json:"usageMetadata"`
+	ModelVersion  string              `json:"modelVersion"`
+}
+
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type GeminiErrorResponse struct {
+	Error GeminiError `json:"error"`
+}
+
+type GeminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// ToOpenAIRequest 转换为 OpenAI 对话请求, 包含不支持的 part 时返回错误
+func (r *GeminiGenerateContentRequest) ToOpenAIRequest(modelName string, stream bool) (OpenAIChatCompletionRequest, error) {
+	openAIReq := OpenAIChatCompletionRequest{
+		Model:  modelName,
+		Stream: stream,
+		Stop:   r.StopSequences(),
+	}
+	if r.GenerationConfig != nil {
+		openAIReq.MaxTokens = r.GenerationConfig.MaxOutputTokens
+		openAIReq.Temperature = r.GenerationConfig.Temperature
+	}
+
+	if r.SystemInstruction != nil {
+		for i, part := range r.SystemInstruction.Parts {
+			if part.InlineData != nil {
+				return openAIReq, fmt.Errorf("systemInstruction.parts[%d]: inlineData is not supported in system instruction", i)
+			}
+		}
+		if system := r.SystemInstruction.Text(); system != "" {
+			openAIReq.Messages = append(openAIReq.Messages, OpenAIChatMessage{
+				Role:    "system",
+				Content: system,
+			})
+		}
+	}
+
+	for i, content := range r.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		messageContent, err := content.openAIContent()
+		if err != nil {
+			return openAIReq, fmt.Errorf("contents[%d].%v", i, err)
+		}
+		openAIReq.Messages = append(openAIReq.Messages, OpenAIChatMessage{
+			Role:    role,
+			Content: messageContent,
+		})
+	}
+
+	return openAIReq, nil
+}
+
+// openAIContent 无 inlineData 时返回拼接的文本, 否则返回 text 及 image_url 内容块
+func (c *GeminiContent) openAIContent() (interface{}, error) {
+	hasInlineData := false
+	for _, part := range c.Parts {
+		if part.InlineData != nil {
+			hasInlineData = true
+			break
+		}
+	}
+	if !hasInlineData {
+		for i, part := range c.Parts {
+			if part.Text == "" {
+				return nil, fmt.Errorf("parts[%d]: only text and inlineData parts are supported", i)
+			}
+		}
+		return c.Text(), nil
+	}
+
+	var parts []interface{}
+	for i, part := range c.Parts {
+		switch {
+		case part.InlineData != nil:
+			if !strings.HasPrefix(part.InlineData.MimeType, "image/") || part.InlineData.Data == "" {
+				return nil, fmt.Errorf("parts[%d]: unsupported inlineData mimeType %q", i, part.InlineData.MimeType)
+			}
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data)},
+			})
+		case part.Text != "":
+			parts = append(parts, map[string]interface{}{"type": "text", "text": part.Text})
+		default:
+			return nil, fmt.Errorf("parts[%d]: only text and inlineData parts are supported", i)
+		}
+	}
+	return parts, nil
+}
+
+// StopSequences 获取停止序列
+func (r *GeminiGenerateContentRequest) StopSequences() []string {
+	if r.GenerationConfig == nil {
+		return nil
+	}
+	return r.GenerationConfig.StopSequences
+}
+
+// Text 拼接所有文本 part
+func (c *GeminiContent) Text() string {
+	var texts []string
+	for _, part := range c.Parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ollamaDefaultTag Ollama 客户端使用的默认模型标签
+const ollamaDefaultTag = ":latest"
+
+// OllamaModelName 去除 Ollama 模型名的 :latest 标签
+func OllamaModelName(name string) string {
+	return strings.TrimSuffix(name, ollamaDefaultTag)
+}
+
+// OllamaTaggedName 返回带 :latest 标签的模型名, 与 Ollama 的模型列表格式一致
+func OllamaTaggedName(name string) string {
+	return OllamaModelName(name) + ollamaDefaultTag
+}
+
+type OllamaChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []OllamaChatMessage `json:"messages"`
+	Stream   *bool               `json:"stream"`
+	Options  *OllamaOptions      `json:"options"`
+}
+
+type OllamaGenerateRequest struct {
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	System  string         `json:"system"`
+	Images  []string       `json:"images,omitempty"`
+	Stream  *bool          `json:"stream"`
+	Options *OllamaOptions `json:"options"`
+}
+
+type OllamaChatMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type OllamaOptions struct {
+	Temperature float64  `json:"temperature"`
+	NumPredict  int      `json:"num_predict"`
+	Stop        []string `json:"stop"`
+}
+
+type OllamaShowRequest struct {
+	Model string `json:"model"`
+	Name  string `json:"name"`
+}
+
+type OllamaModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	ModifiedAt string             `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+type OllamaShowResponse struct {
+	Modelfile  string                 `json:"modelfile"`
+	Parameters string                 `json:"parameters"`
+	Template   string                 `json:"template"`
+	Details    OllamaModelDetails     `json:"details"`
+	ModelInfo  map[string]interface{} `json:"model_info"`
+}
+
+type OllamaChatResponse struct {
+	Model      string             `json:"model"`
+	CreatedAt  string             `json:"created_at"`
+	Message    *OllamaChatMessage `json:"message,omitempty"`
+	Response   *string            `json:"response,omitempty"`
+	Done       bool               `json:"done"`
+	DoneReason string             `json:"done_reason,omitempty"`
+	// 以下字段仅在 done 为 true 时返回
+	TotalDuration   int64 `json:"total_duration,omitempty"`
+	PromptEvalCount int   `json:"prompt_eval_count,omitempty"`
+	EvalCount       int   `json:"eval_count,omitempty"`
+}
+
+// ToOpenAIRequest 转换为 OpenAI 对话请求, images 转换为 image_url 内容块
+func (r *OllamaChatRequest) ToOpenAIRequest() (OpenAIChatCompletionRequest, error) {
+	openAIReq := OpenAIChatCompletionRequest{
+		Model:  OllamaModelName(r.Model),
+		Stream: r.Stream == nil || *r.Stream,
+	}
+	r.Options.apply(&openAIReq)
+	for i, msg := range r.Messages {
+		content, err := ollamaContent(msg.Content, msg.Images)
+		if err != nil {
+			return openAIReq, fmt.Errorf("messages[%d]: %v", i, err)
+		}
+		openAIReq.Messages = append(openAIReq.Messages, OpenAIChatMessage{
+			Role:    msg.Role,
+			Content: content,
+		})
+	}
+	return openAIReq, nil
+}
+
+// ToOpenAIRequest 将 prompt 包装为一轮 human 对话
+func (r *OllamaGenerateRequest) ToOpenAIRequest() (OpenAIChatCompletionRequest, error) {
+	openAIReq := OpenAIChatCompletionRequest{
+		Model:  OllamaModelName(r.Model),
+		Stream: r.Stream == nil || *r.Stream,
+	}
+	r.Options.apply(&openAIReq)
+	if r.System != "" {
+		openAIReq.Messages = append(openAIReq.Messages, OpenAIChatMessage{
+			Role:    "system",
+			Content: r.System,
+		})
+	}
+	content, err := ollamaContent(r.Prompt, r.Images)
+	if err != nil {
+		return openAIReq, err
+	}
+	openAIReq.Messages = append(openAIReq.Messages, OpenAIChatMessage{
+		Role:    "user",
+		Content: content,
+	})
+	return openAIReq, nil
+}
+
+// ollamaContent 无图片时返回文本, 否则返回文本及 image_url 内容块
+// Ollama 的图片为不带前缀的 base64, 按内容识别类型后转换为 data URL
+func ollamaContent(text string, images []string) (interface{}, error) {
+	if len(images) == 0 {
+		return text, nil
+	}
+	parts := []interface{}{map[string]interface{}{"type": "text", "text": text}}
+	for i, image := range images {
+		data, err := base64.StdEncoding.DecodeString(image)
+		if err != nil {
+			return nil, fmt.Errorf("images[%d] is not valid base64", i)
+		}
+		mimeType := http.DetectContentType(data)
+		if !strings.HasPrefix(mimeType, "image/") {
+			return nil, fmt.Errorf("images[%d] has unsupported content type %s", i, mimeType)
+		}
+		parts = append(parts, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": fmt.Sprintf("data:%s;base64,%s", mimeType, image)},
+		})
+	}
+	return parts, nil
+}
+
+func (o *OllamaOptions) apply(openAIReq *OpenAIChatCompletionRequest) {
+	if o == nil {
+		return
+	}
+	openAIReq.Temperature = o.Temperature
+	openAIReq.MaxTokens = o.NumPredict
+	openAIReq.Stop = o.Stop
+}
@@ -11,6 +11,55 @@ type OpenAIChatCompletionRequest struct {
 	Messages    []OpenAIChatMessage `json:"messages"`
 	MaxTokens   int                 `json:"max_tokens"`
 	Temperature float64             `json:"temperature"`
+	Tools       []OpenAITool        `json:"tools,omitempty"`
+	// ToolChoice "none"、"auto"、"required" 或 {"type":"function","function":{"name":"..."}}
+	ToolChoice     interface{}           `json:"tool_choice,omitempty"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+	// Stop 字符串或字符串数组, 最多 4 个
+	Stop interface{} `json:"stop,omitempty"`
+	// N 生成的回复数量, 默认 1
+	N int `json:"n,omitempty"`
+	// ReasoningEffort none、minimal、low、medium 或 high, 用于选择思考模型变体
+	ReasoningEffort string               `json:"reasoning_effort,omitempty"`
+	StreamOptions   *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	// AutoContinue 回复因 max_tokens 截断时自动续写, 也可通过请求头 X-Auto-Continue: true 开启
+	AutoContinue bool `json:"auto_continue,omitempty"`
+}
+
+// OpenAIStreamOptions IncludeUsage 为 true 时流式响应结束前返回用量分片
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChoiceCount 需要生成的回复数量
+func (r *OpenAIChatCompletionRequest) ChoiceCount() int {
+	if r.N < 1 {
+		return 1
+	}
+	return r.N
+}
+
+// StopSequences 获取停止序列
+func (r *OpenAIChatCompletionRequest) StopSequences() []string {
+	return stopSequences(r.Stop)
+}
+
+// OpenAIResponseFormat Type 为 text、json_object 或 json_schema
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Schema      interface{} `json:"schema,omitempty"`
+	Strict      *bool       `json:"strict,omitempty"`
+}
+
+// IsJSON 是否要求输出 JSON
+func (f *OpenAIResponseFormat) IsJSON() bool {
+	return f != nil && (f.Type == "json_object" || f.Type == "json_schema")
 }
 
 type OpenAIChatCompletionExtraRequest struct {
@@ -24,8 +73,35 @@ type SessionState struct {
 	AnswerIsFinished bool     `json:"answer_is_finished"`
 }
 type OpenAIChatMessage struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+type OpenAIFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+}
+
+type OpenAIToolCall struct {
+	// Index 仅流式响应返回
+	Index    *int               `json:"index,omitempty"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+type OpenAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
 }
 
 func (r *OpenAIChatCompletionRequest) AddMessage(message OpenAIChatMessage) {
@@ -53,16 +129,19 @@ func (r *OpenAIChatCompletionRequest) PrependMessagesFromJSON(jsonString string)
 	return nil
 }
 
-func (r *OpenAIChatCompletionRequest) SystemMessagesProcess() {
+// SystemMessagesProcess 规范化 system 类消息: developer 视为 system, 模型不支持 system 时转为 user
+func (r *OpenAIChatCompletionRequest) SystemMessagesProcess(systemSupported bool) {
 	if r.Messages == nil {
 		return
 	}
 
 	for i := range r.Messages {
-		if r.Messages[i].Role == "system" {
+		if r.Messages[i].Role == "developer" {
+			r.Messages[i].Role = "system"
+		}
+		if r.Messages[i].Role == "system" && !systemSupported {
 			r.Messages[i].Role = "user"
 		}
-
 	}
 }
 
@@ -73,6 +152,12 @@ func (r *OpenAIChatCompletionRequest) RemoveEmptyContentMessages() *OpenAIChatCo
 
 	var filteredMessages []OpenAIChatMessage
 	for _, msg := range r.Messages {
+		// 工具调用消息的 content 可以为空
+		if len(msg.ToolCalls) > 0 {
+			filteredMessages = append(filteredMessages, msg)
+			continue
+		}
+
 		// Check if content is nil
 		if msg.Content == nil {
 			continue
@@ -122,14 +207,17 @@ type OpenAIError struct {
 }
 
 type OpenAIChatCompletionResponse struct {
-	ID                string         `json:"id"`
-	Object            string         `json:"object"`
-	Created           int64          `json:"created"`
-	Model             string         `json:"model"`
-	Choices           []OpenAIChoice `json:"choices"`
-	Usage             OpenAIUsage    `json:"usage"`
-	SystemFingerprint *string        `json:"system_fingerprint"`
-	Suggestions       []string       `json:"suggestions"`
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	// Usage 流式响应仅在最后一个分片返回
+	Usage             *OpenAIUsage `json:"usage,omitempty"`
+	SystemFingerprint *string      `json:"system_fingerprint"`
+	Suggestions       []string     `json:"suggestions"`
+	// PromptFilterResults Azure 风格接口返回
+	PromptFilterResults []AzurePromptFilterResult `json:"prompt_filter_results,omitempty"`
 }
 
 type OpenAIChoice struct {
@@ -138,22 +226,35 @@ type OpenAIChoice struct {
 	LogProbs     *string       `json:"logprobs"`
 	FinishReason *string       `json:"finish_reason"`
 	Delta        OpenAIDelta   `json:"delta"`
+	// ContentFilterResults Azure 风格接口返回
+	ContentFilterResults *AzureContentFilterResults `json:"content_filter_results,omitempty"`
 }
 
 type OpenAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
+	// ReasoningContent 思考模型的思考过程
+	ReasoningContent string `json:"reasoning_content,omitempty"`
 }
 
 type OpenAIUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens            int                            `json:"prompt_tokens"`
+	CompletionTokens        int                            `json:"completion_tokens"`
+	TotalTokens             int                            `json:"total_tokens"`
+	CompletionTokensDetails *OpenAICompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+// OpenAICompletionTokensDetails ReasoningTokens 已计入 CompletionTokens
+type OpenAICompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
 }
 
 type OpenAIDelta struct {
-	Content string `json:"content"`
-	Role    string `json:"role"`
+	Content          string           `json:"content"`
+	Role             string           `json:"role"`
+	ToolCalls        []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ReasoningContent string           `json:"reasoning_content,omitempty"`
 }
 
 type OpenAIImagesGenerationRequest struct {
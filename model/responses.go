@@ -0,0 +1,215 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+type OpenAIResponsesRequest struct {
+	Model              string                    `json:"model"`
+	Input              interface{}               `json:"input"`
+	Instructions       string                    `json:"instructions"`
+	PreviousResponseID string                    `json:"previous_response_id"`
+	MaxOutputTokens    int                       `json:"max_output_tokens"`
+	Temperature        float64                   `json:"temperature"`
+	Stream             bool                      `json:"stream"`
+	Background         bool                      `json:"background"`
+	Store              *bool                     `json:"store"`
+	Reasoning          *OpenAIResponsesReasoning `json:"reasoning,omitempty"`
+}
+
+type OpenAIResponsesReasoning struct {
+	Effort string `json:"effort"`
+}
+
+type OpenAIResponse struct {
+	ID                 string                     `json:"id"`
+	Object             string                     `json:"object"`
+	CreatedAt          int64                      `json:"created_at"`
+	Status             string                     `json:"status"`
+	Background         bool                       `json:"background"`
+	Error              *OpenAIResponseError       `json:"error"`
+	IncompleteDetails  *OpenAIIncompleteDetails   `json:"incomplete_details"`
+	Instructions       *string                    `json:"instructions"`
+	MaxOutputTokens    *int                       `json:"max_output_tokens"`
+	Model              string                     `json:"model"`
+	Output             []OpenAIResponseOutputItem `json:"output"`
+	PreviousResponseID *string                    `json:"previous_response_id"`
+	Temperature        float64                    `json:"temperature"`
+	Usage              *OpenAIResponseUsage       `json:"usage"`
+}
+
+type OpenAIResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type OpenAIIncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+type OpenAIResponseOutputItem struct {
+	Type    string                  `json:"type"`
+	ID      string                  `json:"id"`
+	Status  string                  `json:"status"`
+	Role    string                  `json:"role"`
+	Content []OpenAIResponseContent `json:"content"`
+}
+
+type OpenAIResponseContent struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+type OpenAIResponseUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type OpenAIResponseStreamEvent struct {
+	Type           string                    `json:"type"`
+	SequenceNumber int                       `json:"sequence_number"`
+	Response       *OpenAIResponse           `json:"response,omitempty"`
+	OutputIndex    *int                      `json:"output_index,omitempty"`
+	ContentIndex   *int                      `json:"content_index,omitempty"`
+	ItemID         string                    `json:"item_id,omitempty"`
+	Item           *OpenAIResponseOutputItem `json:"item,omitempty"`
+	Part           *OpenAIResponseContent    `json:"part,omitempty"`
+	Delta          *string                   `json:"delta,omitempty"`
+	Text           *string                   `json:"text,omitempty"`
+}
+
+// InputMessages 将 input (字符串或输入项数组) 转换为对话消息
+// function_call 项转换为助手的 tool_calls, function_call_output 项转换为 tool 消息, 不支持的输入项返回错误
+func (r *OpenAIResponsesRequest) InputMessages() ([]OpenAIChatMessage, error) {
+	switch v := r.Input.(type) {
+	case string:
+		return []OpenAIChatMessage{{Role: "user", Content: v}}, nil
+	case []interface{}:
+		var messages []OpenAIChatMessage
+		for i, it := range v {
+			item, ok := it.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("input[%d]: expected an object", i)
+			}
+			itemType, _ := item["type"].(string)
+			switch itemType {
+			case "", "message":
+				role, _ := item["role"].(string)
+				if role == "" {
+					role = "user"
+				}
+				content, err := responseContent(item["content"])
+				if err != nil {
+					return nil, fmt.Errorf("input[%d].%v", i, err)
+				}
+				messages = append(messages, OpenAIChatMessage{
+					Role:    role,
+					Content: content,
+				})
+			case "function_call":
+				callID, _ := item["call_id"].(string)
+				name, _ := item["name"].(string)
+				arguments, _ := item["arguments"].(string)
+				toolCall := OpenAIToolCall{
+					ID:       callID,
+					Type:     "function",
+					Function: OpenAIFunctionCall{Name: name, Arguments: arguments},
+				}
+				// 连续的函数调用及其前面的助手消息合并为一条消息
+				if last := len(messages) - 1; last >= 0 && messages[last].Role == "assistant" {
+					messages[last].ToolCalls = append(messages[last].ToolCalls, toolCall)
+					continue
+				}
+				messages = append(messages, OpenAIChatMessage{
+					Role:      "assistant",
+					Content:   "",
+					ToolCalls: []OpenAIToolCall{toolCall},
+				})
+			case "function_call_output":
+				callID, _ := item["call_id"].(string)
+				output, err := responseContent(item["output"])
+				if err != nil {
+					return nil, fmt.Errorf("input[%d].output: %v", i, err)
+				}
+				messages = append(messages, OpenAIChatMessage{
+					Role:       "tool",
+					ToolCallID: callID,
+					Content:    output,
+				})
+			case "reasoning":
+				// 历史回复的思考过程不再发送给模型
+			default:
+				return nil, fmt.Errorf("input[%d]: unsupported input item type %s", i, itemType)
+			}
+		}
+		return messages, nil
+	}
+	return nil, nil
+}
+
+// ToOpenAIRequest 转换为 OpenAI 对话请求, messages 为历史消息及本次输入
+func (r *OpenAIResponsesRequest) ToOpenAIRequest(messages []OpenAIChatMessage) OpenAIChatCompletionRequest {
+	openAIReq := OpenAIChatCompletionRequest{
+		Model:       r.Model,
+		Stream:      r.Stream,
+		MaxTokens:   r.MaxOutputTokens,
+		Temperature: r.Temperature,
+	}
+	if r.Instructions != "" {
+		openAIReq.Messages = append(openAIReq.Messages, OpenAIChatMessage{
+			Role:    "system",
+			Content: r.Instructions,
+		})
+	}
+	openAIReq.Messages = append(openAIReq.Messages, messages...)
+	return openAIReq
+}
+
+// responseContent 转换 content (字符串或内容数组), 无图片时返回拼接的文本, 否则返回 text 及 image_url 内容块
+func responseContent(content interface{}) (interface{}, error) {
+	items, ok := content.([]interface{})
+	if !ok {
+		text, _ := content.(string)
+		return text, nil
+	}
+
+	var texts []string
+	var parts []interface{}
+	hasImage := false
+	for j, it := range items {
+		part, ok := it.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("content[%d]: expected an object", j)
+		}
+		switch part["type"] {
+		case "input_text", "output_text", "text":
+			text, _ := part["text"].(string)
+			texts = append(texts, text)
+			parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+		case "refusal":
+			text, _ := part["refusal"].(string)
+			texts = append(texts, text)
+			parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+		case "input_image":
+			imageURL, _ := part["image_url"].(string)
+			if imageURL == "" {
+				return nil, fmt.Errorf("content[%d]: input_image requires image_url, file_id is not supported", j)
+			}
+			hasImage = true
+			image := map[string]interface{}{"url": imageURL}
+			if detail, ok := part["detail"].(string); ok {
+				image["detail"] = detail
+			}
+			parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": image})
+		default:
+			return nil, fmt.Errorf("content[%d]: unsupported content type %v", j, part["type"])
+		}
+	}
+	if hasImage {
+		return parts, nil
+	}
+	return strings.Join(texts, "\n"), nil
+}
@@ -66,6 +66,16 @@ func getTokenNum(tokenEncoder *tiktoken.Tiktoken, text string) int {
 }
 
 func CountTokenMessages(messages []OpenAIChatMessage, model string) int {
+	tokenNum := 0
+	for _, message := range messages {
+		tokenNum += CountTokenMessage(message, model)
+	}
+	tokenNum += 3 // Every reply is primed with <|start|>assistant<|message|>
+	return tokenNum
+}
+
+// CountTokenMessage 计算单条消息的 token 数, 不含回复开头的 3 个 token
+func CountTokenMessage(message OpenAIChatMessage, model string) int {
 	tokenEncoder := getTokenEncoder(model)
 	// Reference:
 	// https://github.com/openai/openai-cookbook/blob/main/examples/How_to_count_tokens_with_tiktoken.ipynb
@@ -78,43 +88,40 @@ func CountTokenMessages(messages []OpenAIChatMessage, model string) int {
 	} else {
 		tokensPerMessage = 3
 	}
-	tokenNum := 0
-	for _, message := range messages {
-		tokenNum += tokensPerMessage
-		switch v := message.Content.(type) {
-		case string:
-			tokenNum += getTokenNum(tokenEncoder, v)
-		case []any:
-			for _, it := range v {
-				m := it.(map[string]any)
-				switch m["type"] {
-				case "text":
-					if textValue, ok := m["text"]; ok {
-						if textString, ok := textValue.(string); ok {
-							tokenNum += getTokenNum(tokenEncoder, textString)
-						}
+	tokenNum := tokensPerMessage
+	switch v := message.Content.(type) {
+	case string:
+		tokenNum += getTokenNum(tokenEncoder, v)
+	case []any:
+		for _, it := range v {
+			// 跳过格式不正确的内容块, 避免计算用量时 panic
+			m, ok := it.(map[string]any)
+			if !ok {
+				continue
+			}
+			switch m["type"] {
+			case "text":
+				if textValue, ok := m["text"]; ok {
+					if textString, ok := textValue.(string); ok {
+						tokenNum += getTokenNum(tokenEncoder, textString)
 					}
-				case "image_url":
-					imageUrl, ok := m["image_url"].(map[string]any)
-					if ok {
-						url := imageUrl["url"].(string)
-						detail := ""
-						if imageUrl["detail"] != nil {
-							detail = imageUrl["detail"].(string)
-						}
-						imageTokens, err := countImageTokens(url, detail, model)
-						if err != nil {
-							logger.SysError("error counting image tokens: " + err.Error())
-						} else {
-							tokenNum += imageTokens
-						}
+				}
+			case "image_url":
+				imageUrl, ok := m["image_url"].(map[string]any)
+				if ok {
+					url, _ := imageUrl["url"].(string)
+					detail, _ := imageUrl["detail"].(string)
+					imageTokens, err := countImageTokens(url, detail, model)
+					if err != nil {
+						logger.SysError("error counting image tokens: " + err.Error())
+					} else {
+						tokenNum += imageTokens
 					}
 				}
 			}
 		}
-		tokenNum += getTokenNum(tokenEncoder, message.Role)
 	}
-	tokenNum += 3 // Every reply is primed with <|start|>assistant<|message|>
+	tokenNum += getTokenNum(tokenEncoder, message.Role)
 	return tokenNum
 }
 
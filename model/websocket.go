@@ -0,0 +1,19 @@
+package model
+
+// WebSocketClientFrame 客户端帧
+// type 为 chat 时开始一次生成, 为 cancel 时取消对应 id 的生成
+type WebSocketClientFrame struct {
+	Type    string                       `json:"type"`
+	ID      string                       `json:"id"`
+	Request *OpenAIChatCompletionRequest `json:"request,omitempty"`
+}
+
+// WebSocketServerFrame 服务端帧
+// type 为 delta、done、cancelled 或 error, id 与客户端帧一致
+// delta 帧与 HTTP 流式分片相同(含 finish_reason), done 帧携带用量
+type WebSocketServerFrame struct {
+	Type  string                        `json:"type"`
+	ID    string                        `json:"id"`
+	Data  *OpenAIChatCompletionResponse `json:"data,omitempty"`
+	Error *OpenAIError                  `json:"error,omitempty"`
+}
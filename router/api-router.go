@@ -27,9 +27,42 @@ func SetApiRouter(router *gin.Engine) {
 	v1Router := router.Group(fmt.Sprintf("%s/v1", ProcessPath(config.RoutePrefix)))
 	v1Router.Use(middleware.OpenAIAuth())
 	v1Router.POST("/chat/completions", controller.ChatForOpenAI)
+	v1Router.GET("/chat/ws", controller.ChatForWebSocket)
+	v1Router.POST("/completions", controller.CompletionsForOpenAI)
+	v1Router.POST("/messages", controller.MessagesForAnthropic)
+	v1Router.POST("/responses", controller.ResponsesForOpenAI)
+	v1Router.GET("/responses/:id", controller.GetResponseForOpenAI)
+	v1Router.DELETE("/responses/:id", controller.DeleteResponseForOpenAI)
+	v1Router.POST("/responses/:id/cancel", controller.CancelResponseForOpenAI)
 	//v1Router.POST("/images/generations", controller.ImagesForOpenAI)
 	v1Router.GET("/models", controller.OpenaiModels)
 
+	v1betaRouter := router.Group(fmt.Sprintf("%s/v1beta", ProcessPath(config.RoutePrefix)))
+	v1betaRouter.Use(middleware.OpenAIAuth())
+	v1betaRouter.POST("/models/*action", controller.GenerateContentForGemini)
+
+	azureRouter := router.Group(fmt.Sprintf("%s/openai", ProcessPath(config.RoutePrefix)))
+	azureRouter.Use(middleware.OpenAIAuth())
+	azureRouter.POST("/deployments/:deployment/chat/completions", controller.ChatForAzure)
+
+	codyRouter := router.Group(fmt.Sprintf("%s/.api", ProcessPath(config.RoutePrefix)))
+	codyRouter.Use(middleware.OpenAIAuth())
+	codyRouter.POST("/completions/stream", controller.CompletionsStreamForCody)
+
+	ollamaRouter := router.Group(fmt.Sprintf("%s/api", ProcessPath(config.RoutePrefix)))
+	ollamaRouter.Use(middleware.OpenAIAuth())
+	ollamaRouter.POST("/chat", controller.ChatForOllama)
+	ollamaRouter.POST("/generate", controller.GenerateForOllama)
+	ollamaRouter.GET("/tags", controller.TagsForOllama)
+	ollamaRouter.POST("/show", controller.ShowForOllama)
+	ollamaRouter.GET("/version", controller.VersionForOllama)
+
+	mcpRouter := router.Group(fmt.Sprintf("%s/mcp", ProcessPath(config.RoutePrefix)))
+	mcpRouter.Use(middleware.OpenAIAuth())
+	mcpRouter.POST("", controller.MCPForHTTP)
+	mcpRouter.GET("/sse", controller.MCPSSEForHTTP)
+	mcpRouter.POST("/message", controller.MCPMessageForHTTP)
+
 }
 
 func ProcessPath(path string) string {
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"google.golang.org/grpc"
+	"sourcegraph2api/controller"
+	"sourcegraph2api/middleware"
+	"sourcegraph2api/proto/chatpb"
+)
+
+// NewGrpcServer 创建 gRPC 服务, 鉴权与 HTTP 接口共用 API_SECRET
+func NewGrpcServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(middleware.GrpcUnaryAuth()),
+		grpc.ChainStreamInterceptor(middleware.GrpcStreamAuth()),
+	)
+	chatpb.RegisterChatServer(server, &controller.GrpcChatServer{})
+	return server
+}
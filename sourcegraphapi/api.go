@@ -1,9 +1,9 @@
 package sourcegraphapi
 
 import (
+	"context"
 	"fmt"
 	"github.com/deanxv/CycleTLS/cycletls"
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"sourcegraph2api/common"
 	"sourcegraph2api/common/config"
@@ -11,14 +11,28 @@ import (
 )
 
 const (
-	baseURL      = "https://sourcegraph.com"
-	chatEndpoint = baseURL + "/.api/completions/stream?api-version=9&client-name=vscode&client-version=1.82.0"
+	baseURL        = "https://sourcegraph.com"
+	streamEndpoint = baseURL + "/.api/completions/stream"
+	chatQuery      = "api-version=9&client-name=vscode&client-version=1.82.0"
+	chatEndpoint   = streamEndpoint + "?" + chatQuery
 )
 
-func MakeStreamChatRequest(c *gin.Context, client cycletls.CycleTLS, jsonData []byte, cookie string) (<-chan cycletls.SSEResponse, error) {
+func MakeStreamChatRequest(ctx context.Context, client cycletls.CycleTLS, jsonData []byte, cookie string) (<-chan cycletls.SSEResponse, error) {
+	return makeStreamRequest(ctx, client, chatEndpoint, jsonData, cookie)
+}
+
+// MakePassthroughStreamRequest 透传 Cody 客户端的流式请求, rawQuery 为客户端原始查询参数(api-version、client-name 等)
+func MakePassthroughStreamRequest(ctx context.Context, client cycletls.CycleTLS, rawQuery string, jsonData []byte, cookie string) (<-chan cycletls.SSEResponse, error) {
+	if rawQuery == "" {
+		rawQuery = chatQuery
+	}
+	return makeStreamRequest(ctx, client, streamEndpoint+"?"+rawQuery, jsonData, cookie)
+}
+
+func makeStreamRequest(ctx context.Context, client cycletls.CycleTLS, endpoint string, jsonData []byte, cookie string) (<-chan cycletls.SSEResponse, error) {
 	traceParent, err := common.GenerateTraceParent()
 	if err != nil {
-		logger.Errorf(c, "Failed to generate traceparent: %v", err)
+		logger.Errorf(ctx, "Failed to generate traceparent: %v", err)
 		return nil, fmt.Errorf("failed to generate traceparent: %v", err)
 	}
 
@@ -41,11 +55,12 @@ func MakeStreamChatRequest(c *gin.Context, client cycletls.CycleTLS, jsonData []
 		},
 	}
 
-	logger.Debug(c.Request.Context(), fmt.Sprintf("cookie: %v", cookie))
+	logger.Debug(ctx, fmt.Sprintf("cookie: %v", cookie))
 
-	sseChan, err := client.DoSSE(chatEndpoint, options, "POST")
+	// ctx 取消时中断上游连接
+	sseChan, err := client.DoSSEContext(ctx, endpoint, options, "POST")
 	if err != nil {
-		logger.Errorf(c, "Failed to make stream request: %v", err)
+		logger.Errorf(ctx, "Failed to make stream request: %v", err)
 		return nil, fmt.Errorf("Failed to make stream request: %v", err)
 	}
 	return sseChan, nil